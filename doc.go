@@ -42,4 +42,17 @@
 //
 //	<-waitc // Wait for the client to quit
 //
+// Instead of reading the output channel directly, typed events can be handled with a dispatcher:
+//
+//	dispatcher := twitchclient.NewDispatcher()
+//	dispatcher.OnPrivmsg(func(chat *twitchclient.ChatMessage) {
+//		log.Printf("%s: %s", chat.DisplayName, chat.Text)
+//		if chat.Text == "!ping" {
+//			// Messages sent by the client pass the same rate limits as the ones sent to `in`
+//			_ = client.Reply(chat, "pong")
+//		}
+//	})
+//	// Blocks until the connection to Twitch is lost
+//	dispatcher.Run(out)
+//
 package gbc
@@ -12,6 +12,9 @@ import (
 type Limiter struct {
 	Duration time.Duration
 	Limit    int
+	// Expired is checked for every message before sending it. Expired messages are discarded
+	// without using up a period, so the next message is sent instead.
+	Expired func(mssg *gbc.PlatformMessage) bool
 }
 
 // Apply the Limiter as a pipeline step to a channel.
@@ -22,7 +25,7 @@ func (lim *Limiter) Apply(in <-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMe
 	go func() {
 		defer close(out)
 		for range time.NewTicker(lim.Duration / time.Duration(lim.Limit)).C {
-			mssg, ok := <-in
+			mssg, ok := lim.next(in)
 			if !ok {
 				break
 			}
@@ -32,3 +35,13 @@ func (lim *Limiter) Apply(in <-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMe
 
 	return out
 }
+
+// next returns the next message which isn't expired.
+func (lim *Limiter) next(in <-chan *gbc.PlatformMessage) (*gbc.PlatformMessage, bool) {
+	for mssg := range in {
+		if lim.Expired == nil || !lim.Expired(mssg) {
+			return mssg, true
+		}
+	}
+	return nil, false
+}
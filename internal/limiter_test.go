@@ -71,3 +71,42 @@ func TestLimiter_limits(t *testing.T) {
 		}
 	}
 }
+
+func TestLimiter_expired(t *testing.T) {
+	in := make(chan *gbc.PlatformMessage, 5)
+	defer close(in)
+
+	var expired []string
+	lim := Limiter{
+		Duration: 100 * time.Millisecond,
+		Limit:    1,
+		Expired: func(mssg *gbc.PlatformMessage) bool {
+			if mssg.RawMessage == "PRIVMSG #test :old" {
+				expired = append(expired, mssg.RawMessage)
+				return true
+			}
+			return false
+		},
+	}
+	out := lim.Apply(in)
+
+	start := time.Now()
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #test :old"}
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #test :new"}
+
+	select {
+	case mssg := <-out:
+		if mssg.RawMessage != "PRIVMSG #test :new" {
+			t.Errorf("Unexpected message: %q", mssg.RawMessage)
+		}
+		// The expired message doesn't use up a period
+		if took := time.Since(start); took >= 200*time.Millisecond {
+			t.Errorf("Expired message delayed the next one: %v", took)
+		}
+	case <-time.NewTimer(time.Second).C:
+		t.Fatal("Timed out waiting for output")
+	}
+	if len(expired) != 1 {
+		t.Errorf("Expected expired message to be reported: %q", expired)
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"github.com/MoBlaa/gbc"
+	"reflect"
+)
+
+// Prioritize merges the lanes into one channel. Whenever the output is read, the oldest message of
+// the first lane with a waiting message is returned, so messages of earlier lanes overtake the ones
+// of later lanes. The output is closed after all lanes are closed.
+func Prioritize(lanes ...<-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
+	out := make(chan *gbc.PlatformMessage)
+	lanes = append([]<-chan *gbc.PlatformMessage(nil), lanes...)
+
+	go func() {
+		defer close(out)
+		// The next message of each lane, read before the output is ready
+		heads := make([]*gbc.PlatformMessage, len(lanes))
+		for {
+			// Collect waiting messages first, so they are considered when offering the next one
+			for receive(lanes, heads, false) {
+			}
+
+			next := -1
+			for i, head := range heads {
+				if head != nil {
+					next = i
+					break
+				}
+			}
+			if next == -1 {
+				if !receive(lanes, heads, true) {
+					return
+				}
+				continue
+			}
+
+			cases, indices := receiveCases(lanes, heads)
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(out),
+				Send: reflect.ValueOf(heads[next]),
+			})
+			chosen, value, ok := reflect.Select(cases)
+			if chosen == len(indices) {
+				heads[next] = nil
+			} else {
+				store(lanes, heads, indices[chosen], value, ok)
+			}
+		}
+	}()
+
+	return out
+}
+
+// receive reads a message from one of the lanes without a head. Returns false if there was no lane
+// to read from or, if not blocking, no message was waiting.
+func receive(lanes []<-chan *gbc.PlatformMessage, heads []*gbc.PlatformMessage, block bool) bool {
+	cases, indices := receiveCases(lanes, heads)
+	if len(cases) == 0 {
+		return false
+	}
+	if !block {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	chosen, value, ok := reflect.Select(cases)
+	if chosen == len(indices) {
+		return false
+	}
+	store(lanes, heads, indices[chosen], value, ok)
+	return true
+}
+
+// receiveCases returns the cases to receive from all open lanes without a head and the indices of
+// the lanes the cases belong to.
+func receiveCases(lanes []<-chan *gbc.PlatformMessage, heads []*gbc.PlatformMessage) ([]reflect.SelectCase, []int) {
+	cases := make([]reflect.SelectCase, 0, len(lanes)+1)
+	indices := make([]int, 0, len(lanes))
+	for i, lane := range lanes {
+		if lane != nil && heads[i] == nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(lane)})
+			indices = append(indices, i)
+		}
+	}
+	return cases, indices
+}
+
+// store the received message as head of the lane or removes the lane if it was closed.
+func store(lanes []<-chan *gbc.PlatformMessage, heads []*gbc.PlatformMessage, lane int, value reflect.Value, ok bool) {
+	if !ok {
+		lanes[lane] = nil
+		return
+	}
+	heads[lane] = value.Interface().(*gbc.PlatformMessage)
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"github.com/MoBlaa/gbc"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func lane(raws ...string) chan *gbc.PlatformMessage {
+	ch := make(chan *gbc.PlatformMessage, len(raws)+5)
+	for _, raw := range raws {
+		ch <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: raw}
+	}
+	return ch
+}
+
+func next(t *testing.T, out <-chan *gbc.PlatformMessage) string {
+	t.Helper()
+	select {
+	case mssg, ok := <-out:
+		if !ok {
+			t.Fatal("Output closed unexpectedly")
+		}
+		return mssg.RawMessage
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for output")
+		return ""
+	}
+}
+
+func TestPrioritize_order(t *testing.T) {
+	critical, normal, bulk := lane("c1"), lane("n1", "n2"), lane("b1")
+	close(critical)
+	close(normal)
+	close(bulk)
+	out := Prioritize(critical, normal, bulk)
+	// Give the goroutine time to read all lanes
+	time.Sleep(10 * time.Millisecond)
+
+	var actual []string
+	for mssg := range out {
+		actual = append(actual, mssg.RawMessage)
+	}
+	if expected := []string{"c1", "n1", "n2", "b1"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected order :: expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestPrioritize_overtake(t *testing.T) {
+	critical, bulk := lane(), lane("b1", "b2", "b3")
+	out := Prioritize(critical, bulk)
+
+	if mssg := next(t, out); mssg != "b1" {
+		t.Fatalf("Unexpected message: %q", mssg)
+	}
+	critical <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "c1"}
+	time.Sleep(10 * time.Millisecond)
+	for _, expected := range []string{"c1", "b2", "b3"} {
+		if mssg := next(t, out); mssg != expected {
+			t.Errorf("Unexpected message :: expected: %q, actual: %q", expected, mssg)
+		}
+	}
+
+	close(critical)
+	close(bulk)
+	select {
+	case _, more := <-out:
+		if more {
+			t.Fatal("Not properly closing output")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out before close")
+	}
+}
@@ -0,0 +1,137 @@
+package twitchclient
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Badge shown next to the name of a user.
+type Badge struct {
+	// Name of the badge, e.g. "moderator" or "subscriber".
+	Name string
+	// Version of the badge. For subscriber badges this is the tier of the badge.
+	Version string
+	// Months the user is subscribed taken from the `badge-info` tag. Only set for
+	// subscriber and founder badges.
+	Months int
+}
+
+// ParseBadges parses the values of the `badges` and `badge-info` tags (`name/version,name/version`).
+// Malformed entries are skipped.
+func ParseBadges(badges, badgeInfo string) []Badge {
+	months := make(map[string]int)
+	for _, info := range splitList(badgeInfo) {
+		slash := strings.IndexByte(info, '/')
+		if slash == -1 {
+			continue
+		}
+		if value, err := strconv.Atoi(info[slash+1:]); err == nil {
+			months[info[:slash]] = value
+		}
+	}
+
+	var result []Badge
+	for _, badge := range splitList(badges) {
+		slash := strings.IndexByte(badge, '/')
+		if slash <= 0 {
+			continue
+		}
+		name := badge[:slash]
+		result = append(result, Badge{
+			Name:    name,
+			Version: badge[slash+1:],
+			Months:  months[name],
+		})
+	}
+	return result
+}
+
+// PermissionLevel is the role of a user in a channel. Levels can be compared, where a higher
+// level includes the permissions of lower ones.
+type PermissionLevel int
+
+const (
+	// PermissionEveryone is the level of users without special role.
+	PermissionEveryone PermissionLevel = iota
+	// PermissionSubscriber is the level of subscribers.
+	PermissionSubscriber
+	// PermissionVIP is the level of VIPs.
+	PermissionVIP
+	// PermissionModerator is the level of moderators.
+	PermissionModerator
+	// PermissionBroadcaster is the level of the owner of the channel.
+	PermissionBroadcaster
+)
+
+func (level PermissionLevel) String() string {
+	switch level {
+	case PermissionEveryone:
+		return "Everyone"
+	case PermissionSubscriber:
+		return "Subscriber"
+	case PermissionVIP:
+		return "VIP"
+	case PermissionModerator:
+		return "Moderator"
+	case PermissionBroadcaster:
+		return "Broadcaster"
+	default:
+		return "PermissionLevel(" + strconv.Itoa(int(level)) + ")"
+	}
+}
+
+// PermissionOf returns the highest permission level the badges grant.
+func PermissionOf(badges []Badge) PermissionLevel {
+	level := PermissionEveryone
+	for _, badge := range badges {
+		var granted PermissionLevel
+		switch badge.Name {
+		case "broadcaster":
+			granted = PermissionBroadcaster
+		case "moderator":
+			granted = PermissionModerator
+		case "vip":
+			granted = PermissionVIP
+		case "subscriber", "founder":
+			granted = PermissionSubscriber
+		}
+		if granted > level {
+			level = granted
+		}
+	}
+	return level
+}
+
+// HasBadge returns if one of the badges has the given name.
+func HasBadge(badges []Badge, name string) bool {
+	for _, badge := range badges {
+		if badge.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Badges parses the `badges` and `badge-info` tags.
+func (tags Tags) Badges() []Badge {
+	return ParseBadges(tags["badges"], tags["badge-info"])
+}
+
+// Permission returns the permission level of the user the tags belong to.
+func (tags Tags) Permission() PermissionLevel {
+	level := PermissionOf(tags.Badges())
+	if tags.Mod() && level < PermissionModerator {
+		level = PermissionModerator
+	}
+	return level
+}
+
+// Badges of the user which sent the message.
+func (mssg *IRCMessage) Badges() []Badge {
+	return mssg.Tags().Badges()
+}
+
+// Permission level of the user which sent the message.
+func (mssg *IRCMessage) Permission() PermissionLevel {
+	return mssg.Tags().Permission()
+}
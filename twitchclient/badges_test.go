@@ -0,0 +1,57 @@
+package twitchclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBadges(t *testing.T) {
+	badges := ParseBadges("moderator/1,subscriber/3012,glhf-pledge/1", "subscriber/14")
+	expected := []Badge{
+		{Name: "moderator", Version: "1"},
+		{Name: "subscriber", Version: "3012", Months: 14},
+		{Name: "glhf-pledge", Version: "1"},
+	}
+	if !reflect.DeepEqual(badges, expected) {
+		t.Errorf("Unexpected badges :: expected: %+v, actual: %+v", expected, badges)
+	}
+
+	if badges := ParseBadges("", ""); len(badges) != 0 {
+		t.Errorf("Empty tag should return no badges: %+v", badges)
+	}
+	if badges := ParseBadges("broken,vip/1", "subscriber/abc"); len(badges) != 1 || badges[0].Name != "vip" {
+		t.Errorf("Malformed entries should be skipped: %+v", badges)
+	}
+}
+
+func TestPermissionOf(t *testing.T) {
+	cases := map[string]PermissionLevel{
+		"":                           PermissionEveryone,
+		"premium/1":                  PermissionEveryone,
+		"subscriber/0":               PermissionSubscriber,
+		"founder/0":                  PermissionSubscriber,
+		"vip/1,subscriber/12":        PermissionVIP,
+		"subscriber/12,moderator/1":  PermissionModerator,
+		"broadcaster/1,subscriber/0": PermissionBroadcaster,
+	}
+	for badges, expected := range cases {
+		if actual := PermissionOf(ParseBadges(badges, "")); actual != expected {
+			t.Errorf("Permission of %q :: expected: %v, actual: %v", badges, expected, actual)
+		}
+	}
+
+	if !(PermissionBroadcaster > PermissionModerator && PermissionModerator > PermissionVIP &&
+		PermissionVIP > PermissionSubscriber && PermissionSubscriber > PermissionEveryone) {
+		t.Error("Permission levels should be ordered")
+	}
+}
+
+func TestChatMessage_Permission(t *testing.T) {
+	chat := parseEvent(t, "@badge-info=subscriber/5;badges=subscriber/3;mod=1 :user!user@user.tmi.twitch.tv PRIVMSG #channel :hi").(*ChatMessage)
+	if chat.Permission != PermissionModerator {
+		t.Errorf("Mod tag should grant moderator permission: %v", chat.Permission)
+	}
+	if len(chat.Badges) != 1 || chat.Badges[0].Months != 5 {
+		t.Errorf("Unexpected badges: %+v", chat.Badges)
+	}
+}
@@ -0,0 +1,74 @@
+package twitchclient
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultCheermotes contains the prefixes of the global cheermotes available in every channel.
+var DefaultCheermotes = []string{
+	"Cheer", "DoodleCheer", "BibleThump", "cheerwhal", "Corgo", "Scoops", "uni", "ShowLove",
+	"Party", "SeemsGood", "Pride", "Kappa", "FrankerZ", "HeyGuys", "DansGame", "EleGiggle",
+	"TriHard", "Kreygasm", "4Head", "SwiftRage", "NotLikeThis", "FailFish", "VoHiYo", "PJSalt",
+	"MrDestructoid", "bday", "RIPCheer", "Shamrock", "BitBoss", "Streamlabs", "Muxy",
+	"HolidayCheer", "Goal", "Anon", "Charity",
+}
+
+// Cheer is a cheermote token in the text of a message, e.g. "Cheer100".
+type Cheer struct {
+	// Prefix of the cheermote as written in the message.
+	Prefix string
+	// Amount of bits cheered with the token.
+	Amount int
+}
+
+// Cheers contained in a message.
+type Cheers []Cheer
+
+// Total returns the sum of bits cheered with all tokens.
+func (cheers Cheers) Total() int {
+	total := 0
+	for _, cheer := range cheers {
+		total += cheer.Amount
+	}
+	return total
+}
+
+// ParseCheers finds all cheermote tokens in the text. A token is a whole word consisting of one of
+// the given prefixes (case-insensitive) directly followed by a positive amount. Other words ending
+// in digits are ignored.
+func ParseCheers(text string, prefixes []string) Cheers {
+	known := make(map[string]struct{}, len(prefixes))
+	for _, prefix := range prefixes {
+		known[strings.ToLower(prefix)] = struct{}{}
+	}
+
+	var cheers Cheers
+	for _, word := range strings.Fields(text) {
+		digits := len(word)
+		for digits > 0 && word[digits-1] >= '0' && word[digits-1] <= '9' {
+			digits--
+		}
+		if digits == 0 || digits == len(word) {
+			continue
+		}
+		if _, ok := known[strings.ToLower(word[:digits])]; !ok {
+			continue
+		}
+		amount, err := strconv.Atoi(word[digits:])
+		if err != nil || amount <= 0 {
+			continue
+		}
+		cheers = append(cheers, Cheer{Prefix: word[:digits], Amount: amount})
+	}
+	return cheers
+}
+
+// ParseCheers finds the cheermote tokens in the text of the message using the default cheermotes
+// and the given custom prefixes of the channel. Returns nil if the message contains no bits.
+func (chat *ChatMessage) ParseCheers(custom ...string) Cheers {
+	if chat.Bits == 0 {
+		return nil
+	}
+	return ParseCheers(chat.Text, append(append([]string{}, DefaultCheermotes...), custom...))
+}
@@ -0,0 +1,37 @@
+package twitchclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCheers(t *testing.T) {
+	cheers := ParseCheers("Cheer100 great stream! kappa50 cheer1 Kappa0 route66 Cheer Cheer10x 4Head5", DefaultCheermotes)
+	expected := Cheers{
+		{Prefix: "Cheer", Amount: 100},
+		{Prefix: "kappa", Amount: 50},
+		{Prefix: "cheer", Amount: 1},
+		{Prefix: "4Head", Amount: 5},
+	}
+	if !reflect.DeepEqual(cheers, expected) {
+		t.Errorf("Unexpected cheers :: expected: %+v, actual: %+v", expected, cheers)
+	}
+	if cheers.Total() != 156 {
+		t.Errorf("Unexpected total: %d", cheers.Total())
+	}
+}
+
+func TestChatMessage_Cheers(t *testing.T) {
+	chat := parseEvent(t, "@bits=150 :user!user@user.tmi.twitch.tv PRIVMSG #channel :Cheer100 ShowLove25 custom25 in 2020").(*ChatMessage)
+	if chat.Cheers.Total() != 125 || len(chat.Cheers) != 2 {
+		t.Errorf("Unexpected cheers with default cheermotes: %+v", chat.Cheers)
+	}
+	if cheers := chat.ParseCheers("Custom"); cheers.Total() != chat.Bits {
+		t.Errorf("Custom cheermotes should be counted: %+v", cheers)
+	}
+
+	chat = parseEvent(t, ":user!user@user.tmi.twitch.tv PRIVMSG #channel :Cheer100").(*ChatMessage)
+	if chat.Cheers != nil {
+		t.Errorf("Messages without bits shouldn't contain cheers: %+v", chat.Cheers)
+	}
+}
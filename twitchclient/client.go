@@ -1,6 +1,8 @@
 package twitchclient
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/MoBlaa/gbc"
 	"github.com/MoBlaa/gbc/twitchclient/modes"
@@ -8,8 +10,9 @@ import (
 	"io"
 	"log"
 	"net/url"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 // TwitchAuthentication contains authentication information for twitch.
@@ -20,31 +23,6 @@ type TwitchAuthentication struct {
 	Token string
 }
 
-var mssgRegex = regexp.MustCompile("([@\\w=:;]+ )?([:\\w!@.]+ )?WHISPER(.*)?")
-
-// Message sent from/to twitch.
-type Message gbc.PlatformMessage
-
-// IsWhisper returns if the message represents a whisper message.
-func (mess Message) IsWhisper() bool {
-	return mssgRegex.MatchString(mess.RawMessage)
-}
-
-// Receipt extracts the first parameter of a whisper or privmsg as that represents the
-// user/channel the message is extracted to.
-func (mess Message) Receipt() string {
-	start := strings.Index(mess.RawMessage, "WHISPER")
-	if start == -1 {
-		start = strings.Index(mess.RawMessage, "PRIVMSG")
-	}
-	if start == -1 {
-		return ""
-	}
-	start += 8
-	end := strings.Index(mess.RawMessage[start:], " ")
-	return mess.RawMessage[start : start+end]
-}
-
 // Client handling communication with twitch.
 type Client struct {
 	server     *url.URL
@@ -54,10 +32,49 @@ type Client struct {
 	tags       bool
 	commands   bool
 	mode       modes.MessageRateMode
+	// continuation is appended to the parts of split messages.
+	continuation string
+	// bypassDuplicates enables varying messages which twitch would reject as duplicates.
+	bypassDuplicates bool
+	// spoolPath is the file persisting accepted messages until they are sent. Empty if disabled.
+	spoolPath   string
+	spoolMaxAge time.Duration
 
+	// lock guards the connection and joined channels which change on reconnects and
+	// JOIN/PART messages sent by the application.
+	lock sync.Mutex
 	conn *websocket.Conn
+	// connected identifies the connection established by the last call of Connect and is closed
+	// when disconnecting. Goroutines of a connection use it, so they never use or close a newer one.
+	connected chan struct{}
+	// dialing is set while Connect establishes a connection without holding the lock.
+	dialing bool
+	joined  []string
+	// writeLock serializes writes to the connection as websockets only support one writer.
+	writeLock sync.Mutex
+	// outbound receives the messages of Say, Reply, Action and Whisper with one channel per lane
+	// of the pipeline. The messages of the application are merged into the normal lane. done is
+	// closed when no more messages are accepted.
+	outbound []chan *gbc.PlatformMessage
+	done     chan struct{}
+	stopOnce *sync.Once
+	spool    *spool
+	// finished is closed when the pipeline of the last connection finished.
+	finished chan struct{}
+
+	rooms      *roomStates
+	self       *selfStates
+	roster     *roster
+	deliveries *deliveries
+	schedules  *schedules
 }
 
+// Number of attempts to connect to twitch again after receiving a RECONNECT.
+const reconnectAttempts = 5
+
+// ErrDisconnected is returned when sending a message while the client isn't connected.
+var ErrDisconnected = errors.New("client is disconnected")
+
 // New creates a new TwitchClient with default parameters applying the given options.
 func New(auth *TwitchAuthentication, opts ...Option) *Client {
 	client := &Client{
@@ -65,14 +82,21 @@ func New(auth *TwitchAuthentication, opts ...Option) *Client {
 			Scheme: "wss",
 			Host:   "irc-ws.chat.twitch.tv:443",
 		},
-		auth:     auth,
-		channels: []string{auth.Username},
-		mode:     modes.USER,
+		auth:       auth,
+		channels:   []string{auth.Username},
+		mode:       modes.USER,
+		rooms:      newRoomStates(),
+		self:       newSelfStates(),
+		roster:     newRoster(),
+		deliveries: newDeliveries(),
 	}
+	client.schedules = newSchedules(client.postScheduled)
 
 	for _, opt := range opts {
 		opt(client)
 	}
+	// Twitch only answers sent messages with the commands capability and echoes nonces in tags
+	client.deliveries.confirm = client.tags && client.commands
 
 	return client
 }
@@ -84,19 +108,274 @@ func send(conn *websocket.Conn, mssg string) error {
 
 // Connect establishes an connection to twitch. Messages sent to the `in` channel are sent to twitch
 // after messaging limits are applied. Returns a channel emitting messages received from twitch.
+// Messages tagged with DeadlineTag are dropped if they can't be sent before their deadline and
+// reported to the handlers registered with OnDropped.
+//
+// If twitch asks the client to reconnect, a new connection is established and all channels are
+// joined again. The returned channel stays open in this case.
 func (client *Client) Connect(in <-chan *gbc.PlatformMessage) (<-chan *gbc.PlatformMessage, error) {
-	if client.conn != nil {
+	client.lock.Lock()
+	if client.conn != nil || client.dialing {
+		client.lock.Unlock()
 		return nil, fmt.Errorf("already listening")
 	}
+	// Reserves the connection, so getters and senders aren't blocked while dialing
+	client.dialing = true
+	previous := client.finished
+	channels := append([]string(nil), client.channels...)
+	client.lock.Unlock()
+
+	conn, spooled, err := client.open(previous, channels)
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.dialing = false
+	if err != nil {
+		return nil, err
+	}
+	client.joined = channels
+	client.conn = conn
+	client.spool = spooled
+	connected := make(chan struct{})
+	client.connected = connected
+	finished := make(chan struct{})
+	client.finished = finished
+	client.schedules.connected()
+	client.outbound = make([]chan *gbc.PlatformMessage, len(priorities))
+	client.done = make(chan struct{})
+	client.stopOnce = new(sync.Once)
+	// Split long messages, so each part is limited as a message on its own
+	split := splitter{
+		MaxLength: maxMessageLength,
+		Marker:    client.continuation,
+		Split: func(mssg *gbc.PlatformMessage, parts int) {
+			client.deliveries.split(nonceOf(mssg.RawMessage), parts)
+		},
+	}
+	merged := make([]<-chan *gbc.PlatformMessage, len(priorities))
+	for i, priority := range priorities {
+		client.outbound[i] = make(chan *gbc.PlatformMessage)
+		var input <-chan *gbc.PlatformMessage
+		if priority == PriorityNormal {
+			input = in
+		}
+		priority := priority
+		// Spooled as soon as accepted, so no accepted message is lost if the process stops
+		accept := func(mssg *gbc.PlatformMessage) []*gbc.PlatformMessage {
+			deadline, _ := deadlineOf(mssg.RawMessage)
+			return spooled.add(split.split(mssg), priority, deadline)
+		}
+		merged[i] = client.merge(input, client.outbound[i], client.done, spooled.replayed(priority), accept)
+	}
 
 	out := make(chan *gbc.PlatformMessage)
 
+	// Start listener to websocket connection
+	go client.listen(conn, connected, out)
+
+	// Start Sender to websocket connection
+	go func() {
+		defer close(finished)
+		// Closed after the pipeline is drained, so messages still sent are marked as done
+		defer spooled.close()
+		// This will also close the websocket, which closes the listener also
+		defer client.disconnect(connected)
+		// Limit the output to twitch
+		lim := limiter{
+			Mode: client.mode,
+			Dropped: func(mssg *gbc.PlatformMessage) {
+				client.deliveries.drop(mssg, ErrDropped)
+				spooled.done(mssg)
+			},
+			Expired: func(mssg *gbc.PlatformMessage) bool {
+				select {
+				case <-connected:
+					// Drains the pipeline after disconnecting without waiting for the limits.
+					// Delivery results were already reported when disconnecting.
+					return true
+				default:
+				}
+				now := time.Now()
+				// Messages are tagged with their deadline, replayed ones might only be known to the spool
+				if !isTaggedExpired(mssg.RawMessage, now) && !spooled.isExpired(mssg, now) {
+					return false
+				}
+				client.deliveries.expired(mssg, now)
+				spooled.done(mssg)
+				return true
+			},
+		}
+		limited := lim.Apply(merged...)
+		if client.bypassDuplicates {
+			// Vary messages after limiting, as the duplicate window starts when a message is sent
+			bypass := duplicateBypass{Window: duplicateWindow, Varied: spooled.replaced}
+			limited = bypass.Apply(limited)
+		}
+		for message := range limited {
+			if message.Platform == gbc.Twitch {
+				nonce := nonceOf(message.RawMessage)
+				select {
+				case <-connected:
+					// Messages left in the pipeline after disconnecting stay spooled unless reported
+					if client.deliveries.dropped(nonce, ErrDisconnected) {
+						spooled.done(message)
+					}
+					continue
+				default:
+				}
+				if !isSafeLine(message.RawMessage) {
+					// Sending would split the message into multiple commands
+					log.Printf("Discarding message containing line breaks: %q", message.RawMessage)
+					client.deliveries.drop(message, ErrUnsafeText)
+					spooled.done(message)
+					continue
+				}
+				// The deadline is only read by the client
+				raw := withoutDeadline(message.RawMessage)
+				client.trackOutbound(raw)
+				// Recorded before writing, as twitch may answer before write returns
+				whisper := Message(*message).IsWhisper()
+				client.deliveries.sending(nonce, channelOf(message.RawMessage), whisper)
+				err := client.write(connected, raw)
+				if err != nil {
+					log.Printf("error sending message: %v", err)
+					if client.deliveries.dropped(nonce, err) {
+						spooled.done(message)
+					}
+					// Stops accepting messages, so the pipeline is drained and closed
+					client.disconnect(connected)
+					continue
+				}
+				client.deliveries.written(nonce, whisper)
+				spooled.done(message)
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// open waits for the pipeline of the previous connection to finish, opens the spool and dials
+// twitch. Called without holding the lock.
+func (client *Client) open(previous chan struct{}, channels []string) (*websocket.Conn, *spool, error) {
+	if client.spoolPath != "" && previous != nil {
+		// The previous pipeline marks the messages it still sends as done in its spool, which has
+		// to be closed before the file is opened again
+		<-previous
+	}
+
+	var spooled *spool
+	if client.spoolPath != "" {
+		var err error
+		spooled, err = openSpool(client.spoolPath, client.spoolMaxAge)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conn, err := client.dial(channels)
+	if err != nil {
+		spooled.close()
+		return nil, nil, err
+	}
+	return conn, spooled, nil
+}
+
+// merge forwards the messages of the application and the typed senders of one lane until the
+// application closes its input or the client disconnects. The input is nil for lanes not
+// receiving messages of the application. The replayed messages are forwarded first. Received
+// messages are passed to accept, which returns the messages to forward, e.g. the parts of a
+// split message.
+func (client *Client) merge(in, outbound <-chan *gbc.PlatformMessage, done chan struct{},
+	replay []*gbc.PlatformMessage, accept func(*gbc.PlatformMessage) []*gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
+	out := make(chan *gbc.PlatformMessage)
+	forward := func(mssgs []*gbc.PlatformMessage) bool {
+		for _, mssg := range mssgs {
+			select {
+			case out <- mssg:
+			case <-done:
+				return false
+			}
+		}
+		return true
+	}
+	go func() {
+		defer close(out)
+		if !forward(replay) {
+			return
+		}
+		for {
+			var mssg *gbc.PlatformMessage
+			select {
+			case received, ok := <-in:
+				if !ok {
+					client.stop(done)
+					return
+				}
+				mssg = received
+			case mssg = <-outbound:
+			case <-done:
+				return
+			}
+			if !forward(accept(mssg)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// stop rejects further messages of the typed senders if done belongs to the current connection.
+// Messages already accepted are still sent.
+func (client *Client) stop(done chan struct{}) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.done == done {
+		client.stopLocked()
+	}
+}
+
+func (client *Client) stopLocked() {
+	if client.stopOnce == nil {
+		return
+	}
+	done := client.done
+	client.stopOnce.Do(func() { close(done) })
+}
+
+// enqueue passes the message to the limiter through the lane of the priority. Blocks until the
+// lane accepts it.
+func (client *Client) enqueue(mssg *gbc.PlatformMessage, priority Priority) error {
+	lane, err := priority.lane()
+	if err != nil {
+		return err
+	}
+	client.lock.Lock()
+	lanes, done := client.outbound, client.done
+	client.lock.Unlock()
+	if lanes == nil {
+		return ErrDisconnected
+	}
+	outbound := lanes[lane]
+	select {
+	case <-done:
+		return ErrDisconnected
+	default:
+	}
+	select {
+	case outbound <- mssg:
+		return nil
+	case <-done:
+		return ErrDisconnected
+	}
+}
+
+// dial connects to twitch, logs in, requests the enabled capabilities and joins the given channels.
+func (client *Client) dial(channels []string) (*websocket.Conn, error) {
 	// Connect to Twitch Websocket-Server
 	conn, _, err := websocket.DefaultDialer.Dial(client.server.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Twitch: %w", err)
 	}
-	client.conn = conn
 
 	mssgs := []string{
 		fmt.Sprintf("PASS %s", client.auth.Token),
@@ -114,7 +393,7 @@ func (client *Client) Connect(in <-chan *gbc.PlatformMessage) (<-chan *gbc.Platf
 		mssgs = append(mssgs, "CAP REQ :twitch.tv/commands")
 	}
 
-	for _, channel := range client.channels {
+	for _, channel := range channels {
 		mssgs = append(mssgs, fmt.Sprintf("JOIN #%s", channel))
 	}
 	log.Printf("Logging in as '%s'", client.auth.Username)
@@ -123,71 +402,284 @@ func (client *Client) Connect(in <-chan *gbc.PlatformMessage) (<-chan *gbc.Platf
 	for _, initMssg := range mssgs {
 		err = send(conn, initMssg)
 		if err != nil {
+			_ = conn.Close()
 			return nil, err
 		}
 	}
+	return conn, nil
+}
 
-	// Start listener to websocket connection
-	go func() {
-		defer close(out)
-		for {
-			_, message, err := client.conn.ReadMessage()
-			if err == io.EOF {
-				return
+// listen reads messages from the connection and emits them to the output channel. The output
+// channel is closed as soon as the connection is lost and can't be replaced.
+func (client *Client) listen(conn *websocket.Conn, connected chan struct{}, out chan<- *gbc.PlatformMessage) {
+	defer close(out)
+	var buffer bytes.Buffer
+	for {
+		_, reader, err := conn.NextReader()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			// Removed as triggered every time the connection is closed
+			//elog.Error(fmt.Errorf("error reading message from twitch: %w", err))
+			log.Printf("error: %v; Closing listener for twitch messages!", err)
+			return
+		}
+		frame, err := readFrame(reader, &buffer)
+		if err != nil {
+			log.Printf("error: %v; Closing listener for twitch messages!", err)
+			return
+		}
+		for frame != "" {
+			var single string
+			single, frame = nextLine(frame)
+			if strings.TrimLeft(single, " ") == "" {
+				continue
+			}
+
+			parsed, err := ParseIRC(single)
+			if err == nil {
+				client.track(parsed)
+			}
+
+			out <- &gbc.PlatformMessage{
+				Platform:   gbc.Twitch,
+				RawMessage: single,
 			}
+
 			if err != nil {
-				// Removed as triggered every time the connection is closed
-				//elog.Error(fmt.Errorf("error reading message from twitch: %w", err))
-				log.Printf("error: %v; Closing listener for twitch messages!", err)
-				return
+				continue
 			}
-			strMssg := string(message)
-			mssgs := strings.Split(strings.ReplaceAll(strMssg, "\r\n", "\n"), "\n")
-			for _, single := range mssgs {
-				if strings.ReplaceAll(single, " ", "") == "" {
+			switch parsed.Command {
+			case "PING":
+				err = client.write(connected, "PONG :tmi.twitch.tv")
+				if err == nil || err == ErrDisconnected {
 					continue
 				}
-
-				out <- &gbc.PlatformMessage{
-					Platform:   gbc.Twitch,
-					RawMessage: single,
+				// Twitch closes connections not answering PINGs, so a new one is established
+				log.Printf("failed to send PONG message: %v", err)
+				conn, err = client.reconnect(conn, connected)
+				if err != nil {
+					log.Printf("error: %v; Closing listener for twitch messages!", err)
+					return
 				}
-
-				if strings.HasSuffix(single, "PING :tmi.twitch.tv") {
-					err = send(client.conn, "PONG :tmi.twitch.tv")
-					if err != nil {
-						log.Fatalf("failed to send PONG message: %v\n", err)
-					}
+			case "RECONNECT":
+				log.Printf("Twitch requested to reconnect")
+				conn, err = client.reconnect(conn, connected)
+				if err != nil {
+					log.Printf("error: %v; Closing listener for twitch messages!", err)
+					return
 				}
 			}
 		}
-	}()
+	}
+}
 
-	// Start Sender to websocket connection
-	go func() {
-		// This will also close the websocket, which closes the listener also
-		defer client.Disconnect()
-		// Limit the output to twitch
-		lim := limiter{Mode: client.mode}
-		for message := range lim.Apply(in) {
-			if message.Platform == gbc.Twitch {
-				err := send(client.conn, message.RawMessage)
-				if err != nil {
-					log.Printf("error sending message: %v", err)
-					break
-				}
-			}
+// reconnect replaces the given connection with a new one. Retries with an increasing delay if
+// connecting fails.
+func (client *Client) reconnect(old *websocket.Conn, connected chan struct{}) (*websocket.Conn, error) {
+	var err error
+	for attempt := 0; attempt < reconnectAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
 		}
-	}()
 
-	return out, nil
+		client.lock.Lock()
+		channels := append([]string(nil), client.joined...)
+		client.lock.Unlock()
+
+		var conn *websocket.Conn
+		conn, err = client.dial(channels)
+		if err != nil {
+			log.Printf("failed to reconnect (attempt %d/%d): %v", attempt+1, reconnectAttempts, err)
+			continue
+		}
+
+		client.lock.Lock()
+		if !client.isConnected(connected) {
+			client.lock.Unlock()
+			_ = conn.Close()
+			return nil, ErrDisconnected
+		}
+		client.conn = conn
+		client.lock.Unlock()
+
+		if err := old.Close(); err != nil {
+			log.Printf("failed to close replaced websocket connection: %v", err)
+		}
+		return conn, nil
+	}
+	_ = old.Close()
+	return nil, fmt.Errorf("failed to reconnect to Twitch: %w", err)
+}
+
+// write sends the message with the current connection, unless the connection identified by
+// connected was closed. If the connection is replaced while writing, the message is sent again
+// with the new connection.
+func (client *Client) write(connected chan struct{}, mssg string) error {
+	client.writeLock.Lock()
+	defer client.writeLock.Unlock()
+	for {
+		client.lock.Lock()
+		conn := client.conn
+		if !client.isConnected(connected) {
+			conn = nil
+		}
+		client.lock.Unlock()
+		if conn == nil {
+			return ErrDisconnected
+		}
+
+		err := send(conn, mssg)
+		if err == nil {
+			return nil
+		}
+		client.lock.Lock()
+		replaced := client.conn != conn
+		client.lock.Unlock()
+		if !replaced {
+			return err
+		}
+	}
+}
+
+// isConnected returns if the connection identified by connected is still the current one. Has to
+// be called while holding the lock.
+func (client *Client) isConnected(connected chan struct{}) bool {
+	if connected == nil || client.connected != connected {
+		return false
+	}
+	select {
+	case <-connected:
+		return false
+	default:
+		return true
+	}
 }
 
 // Disconnect closes the connection to twitch.
 func (client *Client) Disconnect() {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.disconnectLocked()
+}
+
+// disconnect closes the connection identified by connected, unless the client disconnected or
+// connected again in the meantime.
+func (client *Client) disconnect(connected chan struct{}) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.isConnected(connected) {
+		client.disconnectLocked()
+	}
+}
+
+func (client *Client) disconnectLocked() {
+	if client.isConnected(client.connected) {
+		close(client.connected)
+	}
+	client.stopLocked()
+	client.schedules.disconnected()
+	// Messages reported as dropped are removed from the spool, as the application might send them
+	// again. All others stay in the spool file for the next connection.
+	client.spool.discard(client.deliveries.disconnected())
+	client.spool = nil
+	if client.conn == nil {
+		return
+	}
 	err := client.conn.Close()
 	if err != nil {
 		log.Printf("failed to close websocket connection: %v", err)
 	}
 	client.conn = nil
 }
+
+// trackOutbound keeps track of the channels joined and left by the application, so they can
+// be joined again after reconnecting.
+func (client *Client) trackOutbound(raw string) {
+	if command, _ := peekCommand(raw); command != "JOIN" && command != "PART" {
+		return
+	}
+	mssg, err := ParseIRC(raw)
+	if err != nil || (mssg.Command != "JOIN" && mssg.Command != "PART") {
+		return
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	for _, channel := range strings.Split(mssg.Param(0), ",") {
+		channel = strings.TrimPrefix(channel, "#")
+		index := -1
+		for i, joined := range client.joined {
+			if joined == channel {
+				index = i
+				break
+			}
+		}
+		if mssg.Command == "JOIN" && index == -1 && channel != "" {
+			client.joined = append(client.joined, channel)
+		} else if mssg.Command == "PART" && index != -1 {
+			client.joined = append(client.joined[:index], client.joined[index+1:]...)
+		}
+	}
+}
+
+// normalizeChannel returns the channel name as used by twitch, in lower case without the leading '#'.
+func normalizeChannel(channel string) string {
+	return strings.ToLower(strings.TrimPrefix(channel, "#"))
+}
+
+// track updates the state kept by the client with a message received from twitch.
+func (client *Client) track(mssg *IRCMessage) {
+	switch mssg.Command {
+	case "ROOMSTATE":
+		update, err := NewRoomStateUpdate(mssg)
+		if err != nil {
+			log.Printf("failed to track room state: %v", err)
+			return
+		}
+		update.Channel = normalizeChannel(update.Channel)
+		client.rooms.update(update)
+	case "USERSTATE":
+		state, err := NewUserState(mssg)
+		if err != nil {
+			log.Printf("failed to track user state: %v", err)
+			return
+		}
+		state.Channel = normalizeChannel(state.Channel)
+		client.self.update(state)
+		client.deliveries.answered(mssg)
+	case "NOTICE":
+		client.deliveries.answered(mssg)
+	case "PRIVMSG":
+		if !strings.EqualFold(mssg.Prefix.Nick, client.auth.Username) {
+			client.schedules.line(normalizeChannel(mssg.Channel()))
+		}
+	case "GLOBALUSERSTATE":
+		state, err := NewGlobalUserState(mssg)
+		if err != nil {
+			log.Printf("failed to track global user state: %v", err)
+			return
+		}
+		client.self.updateGlobal(state)
+	case "JOIN":
+		if strings.EqualFold(mssg.Prefix.Nick, client.auth.Username) {
+			client.roster.rejoin(normalizeChannel(mssg.Channel()))
+		} else {
+			client.roster.join(normalizeChannel(mssg.Channel()), mssg.Prefix.Nick)
+		}
+	case "PART":
+		if strings.EqualFold(mssg.Prefix.Nick, client.auth.Username) {
+			client.rooms.remove(normalizeChannel(mssg.Channel()))
+			client.self.remove(normalizeChannel(mssg.Channel()))
+			client.roster.reset(normalizeChannel(mssg.Channel()))
+		} else {
+			client.roster.leave(normalizeChannel(mssg.Channel()), mssg.Prefix.Nick)
+		}
+	case "353":
+		// NAMES reply: <user> = #<channel> :<names>
+		client.roster.addNames(normalizeChannel(mssg.Param(2)), strings.Fields(mssg.Trailing))
+	case "366":
+		// End of NAMES: <user> #<channel> :End of /NAMES list
+		client.roster.endNames(normalizeChannel(mssg.Param(1)), client.auth.Username)
+	}
+}
@@ -0,0 +1,298 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"github.com/MoBlaa/gbc/twitchclient/modes"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fakeTwitch is a websocket server standing in for the twitch chat server.
+type fakeTwitch struct {
+	server *httptest.Server
+	conns  chan *fakeConn
+}
+
+// fakeConn is a connection of a client to the fakeTwitch server.
+type fakeConn struct {
+	conn  *websocket.Conn
+	lines chan string
+	// fake is the server the client connected to.
+	fake *fakeTwitch
+}
+
+func newFakeTwitch(t *testing.T) *fakeTwitch {
+	fake := &fakeTwitch{conns: make(chan *fakeConn, 5)}
+	upgrader := websocket.Upgrader{}
+	fake.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade connection: %v", err)
+			return
+		}
+		fc := &fakeConn{conn: conn, lines: make(chan string, 100), fake: fake}
+		fake.conns <- fc
+		go func() {
+			defer close(fc.lines)
+			for {
+				_, message, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				fc.lines <- string(message)
+			}
+		}()
+	}))
+	return fake
+}
+
+func (fake *fakeTwitch) url() *url.URL {
+	return &url.URL{Scheme: "ws", Host: fake.server.Listener.Addr().String()}
+}
+
+// accept waits for the next client connecting to the server.
+func (fake *fakeTwitch) accept(t *testing.T) *fakeConn {
+	t.Helper()
+	select {
+	case conn := <-fake.conns:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for connection")
+		return nil
+	}
+}
+
+// expect the next lines received by the server to be the given ones.
+func (conn *fakeConn) expect(t *testing.T, lines ...string) {
+	t.Helper()
+	for _, expected := range lines {
+		select {
+		case actual, ok := <-conn.lines:
+			if !ok {
+				t.Fatalf("Connection closed while waiting for %q", expected)
+			}
+			if actual != expected {
+				t.Fatalf("Unexpected line :: expected: %q, actual: %q", expected, actual)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %q", expected)
+		}
+	}
+}
+
+func (conn *fakeConn) send(t *testing.T, line string) {
+	t.Helper()
+	if err := conn.conn.WriteMessage(websocket.TextMessage, []byte(line+"\r\n")); err != nil {
+		t.Fatalf("Failed to send %q: %v", line, err)
+	}
+}
+
+// connectFake connects a new client with the given options to a new fakeTwitch server and waits
+// until it logged in. Returns the input and output channels of the connection. The server is
+// closed when the test finishes. The client uses the highest rate limits unless the options set
+// another mode, so tests don't wait for the limiter.
+func connectFake(t *testing.T, opts ...Option) (*Client, chan *gbc.PlatformMessage, <-chan *gbc.PlatformMessage, *fakeConn) {
+	t.Helper()
+	fake := newFakeTwitch(t)
+	t.Cleanup(fake.server.Close)
+	client := New(&TwitchAuthentication{Username: "bot", Token: "oauth:token"},
+		append([]Option{Server(fake.url()), As(modes.VERIFIED)}, opts...)...)
+
+	in := make(chan *gbc.PlatformMessage)
+	out, err := client.Connect(in)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn := fake.accept(t)
+	conn.expect(t, loginLines(client)...)
+	return client, in, out, conn
+}
+
+// loginLines returns the lines the client sends when connecting.
+func loginLines(client *Client) []string {
+	lines := []string{"PASS " + client.auth.Token, "NICK " + client.auth.Username}
+	if client.membership {
+		lines = append(lines, "CAP REQ :twitch.tv/membership")
+	}
+	if client.tags {
+		lines = append(lines, "CAP REQ :twitch.tv/tags")
+	}
+	if client.commands {
+		lines = append(lines, "CAP REQ :twitch.tv/commands")
+	}
+	for _, channel := range client.channels {
+		lines = append(lines, "JOIN #"+channel)
+	}
+	return lines
+}
+
+// receive waits for the next message emitted by the client.
+func receive(t *testing.T, out <-chan *gbc.PlatformMessage) string {
+	t.Helper()
+	select {
+	case mssg, ok := <-out:
+		if !ok {
+			t.Fatal("Output closed unexpectedly")
+		}
+		return mssg.RawMessage
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for output")
+		return ""
+	}
+}
+
+// trackRaw parses the raw message and tracks it with the client.
+func trackRaw(t *testing.T, client *Client, raw string) {
+	t.Helper()
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	client.track(mssg)
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	_, in, out, first := connectFake(t, WithChannels("bot", "other"), WithTags())
+
+	// Channels joined by the application are joined again after reconnecting
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "JOIN #third"}
+	first.expect(t, "JOIN #third")
+
+	first.send(t, ":tmi.twitch.tv RECONNECT")
+	if mssg := receive(t, out); mssg != ":tmi.twitch.tv RECONNECT" {
+		t.Fatalf("Unexpected message: %q", mssg)
+	}
+
+	second := first.fake.accept(t)
+	second.expect(t, "PASS oauth:token", "NICK bot", "CAP REQ :twitch.tv/tags", "JOIN #bot", "JOIN #other", "JOIN #third")
+	select {
+	case _, ok := <-first.lines:
+		if ok {
+			t.Fatal("Replaced connection should only be closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Replaced connection should be closed")
+	}
+
+	// The output channel is still open and receives messages of the new connection
+	second.send(t, "PING :tmi.twitch.tv")
+	if mssg := receive(t, out); mssg != "PING :tmi.twitch.tv" {
+		t.Fatalf("Unexpected message: %q", mssg)
+	}
+	second.expect(t, "PONG :tmi.twitch.tv")
+
+	// Closing the input disconnects the client
+	close(in)
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("Output should be closed after disconnecting")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for output to close")
+	}
+}
+
+func TestClient_DiscardsInjectedLines(t *testing.T) {
+	_, in, _, conn := connectFake(t)
+	defer close(in)
+
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :hi\r\nPART #bot"}
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :safe"}
+	conn.expect(t, "PRIVMSG #bot :safe")
+}
+
+func TestClient_ConnectAfterDisconnect(t *testing.T) {
+	// Messages wait for the chat limit of the KNOWN mode, which keeps the pipeline busy
+	client, _, first, firstConn := connectFake(t, As(modes.KNOWN))
+	finished := client.finished
+	// Queued messages keep the pipeline of the first connection busy after disconnecting
+	for i := 0; i < 3; i++ {
+		if err := client.Say("bot", "queued"); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+	}
+	client.Disconnect()
+	for range first {
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the pipeline of the first connection to finish")
+	}
+
+	in := make(chan *gbc.PlatformMessage)
+	defer close(in)
+	out, err := client.Connect(in)
+	if err != nil {
+		t.Fatalf("Failed to connect again: %v", err)
+	}
+	go func() {
+		for range out {
+		}
+	}()
+	conn := firstConn.fake.accept(t)
+	conn.expect(t, loginLines(client)...)
+
+	// The finished pipeline of the first connection didn't close the new one
+	if err := client.Say("bot", "still connected"); err != nil {
+		t.Fatalf("Failed to send after connecting again: %v", err)
+	}
+	conn.expect(t, "PRIVMSG #bot :still connected")
+}
+
+func TestClient_ConnectWithoutBlocking(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Simulates a slow handshake
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := New(&TwitchAuthentication{Username: "bot", Token: "oauth:token"},
+		Server(&url.URL{Scheme: "ws", Host: server.Listener.Addr().String()}))
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := client.Connect(make(chan *gbc.PlatformMessage))
+		failed <- err
+	}()
+	for dialing := false; !dialing; {
+		time.Sleep(time.Millisecond)
+		client.lock.Lock()
+		dialing = client.dialing
+		client.lock.Unlock()
+	}
+
+	// Getters and senders don't wait for the handshake
+	queried := make(chan error, 1)
+	go func() {
+		client.RoomState("bot")
+		queried <- client.Say("bot", "hi")
+	}()
+	select {
+	case err := <-queried:
+		if err != ErrDisconnected {
+			t.Errorf("Expected ErrDisconnected while connecting but got: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Blocked while connecting")
+	}
+	if _, err := client.Connect(make(chan *gbc.PlatformMessage)); err == nil {
+		t.Error("Connecting twice at the same time should fail")
+	}
+
+	close(release)
+	if err := <-failed; err == nil {
+		t.Fatal("Expected connecting to fail")
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.dialing || client.conn != nil {
+		t.Error("Failed connection attempt should allow connecting again")
+	}
+}
@@ -4,44 +4,66 @@ import (
 	"github.com/MoBlaa/gbc"
 	"github.com/MoBlaa/gbc/internal"
 	"log"
+	"sync"
 )
 
 // dailyLimiter limits the amount of accounts the client can emit messages to.
+// Applying it to multiple channels shares the limit between them.
 type dailyLimiter struct {
 	Limit int
 	Clock internal.Clock
+	// Dropped is called with messages discarded because the limit is reached.
+	Dropped func(mssg *gbc.PlatformMessage)
+	// Expired is checked before an account is recorded as contacted. Expired messages are discarded.
+	Expired func(mssg *gbc.PlatformMessage) bool
+
+	once              sync.Once
+	lock              sync.Mutex
+	contactedAccounts map[string]struct{}
 }
 
 // Apply the dailyLimiter as a pipeline step to a channel.
 func (lim *dailyLimiter) Apply(in <-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
 	out := make(chan *gbc.PlatformMessage, lim.Limit)
 
-	var clock internal.Clock
-	if lim.Clock == nil {
-		clock = internal.NewClock()
-	} else {
-		clock = lim.Clock
-	}
+	lim.once.Do(func() {
+		if lim.Clock == nil {
+			lim.Clock = internal.NewClock()
+		}
+		lim.contactedAccounts = make(map[string]struct{})
+	})
 	go func() {
 		defer close(out)
-		contactedAccounts := make(map[string]struct{})
 		for platformMessage := range in {
-			mssg := Message(*platformMessage)
-			if clock.DaySwitched() {
-				// Reset records of sent targets if day changes
-				contactedAccounts = make(map[string]struct{})
+			if lim.Expired != nil && lim.Expired(platformMessage) {
+				continue
 			}
-
-			if _, contained := contactedAccounts[mssg.Receipt()]; !contained && len(contactedAccounts) >= lim.Limit {
-				// Output, that limit was reached and discard message
-				log.Printf("Reached limit of unique users to send whispers to. Discarding message sent to: %v\n", mssg.Receipt())
-			} else {
-				// Add target and send message to output
-				contactedAccounts[mssg.Receipt()] = struct{}{}
+			if lim.allow(Message(*platformMessage).Receipt()) {
 				out <- platformMessage
+			} else if lim.Dropped != nil {
+				lim.Dropped(platformMessage)
 			}
 		}
 	}()
 
 	return out
 }
+
+// allow records the account as contacted if the limit isn't reached yet.
+func (lim *dailyLimiter) allow(account string) bool {
+	lim.lock.Lock()
+	defer lim.lock.Unlock()
+	if lim.Clock.DaySwitched() {
+		// Reset records of sent targets if day changes
+		lim.contactedAccounts = make(map[string]struct{})
+	}
+
+	if _, contained := lim.contactedAccounts[account]; !contained && len(lim.contactedAccounts) >= lim.Limit {
+		// Output, that limit was reached and discard message
+		log.Printf("Reached limit of unique users to send whispers to. Discarding message sent to: %v\n", account)
+		return false
+	}
+	// Add target and send message to output
+	lim.contactedAccounts[account] = struct{}{}
+	return true
+}
@@ -2,6 +2,7 @@ package twitchclient
 
 import (
 	"github.com/MoBlaa/gbc"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -75,6 +76,49 @@ func TestDailyLimiter_limits(t *testing.T) {
 	}
 }
 
+// Test daily limiter counts the receivers of whispers sent as chat command
+func TestDailyLimiter_whisperCommand(t *testing.T) {
+	mssgs := []*gbc.PlatformMessage{
+		{
+			Platform:   gbc.Twitch,
+			RawMessage: "PRIVMSG #jtv :/w one :D:",
+		},
+		{
+			Platform:   gbc.Twitch,
+			RawMessage: "PRIVMSG #jtv :/w One again",
+		},
+		{
+			Platform:   gbc.Twitch,
+			RawMessage: "PRIVMSG #jtv :/w two :D:",
+		},
+		{
+			Platform:   gbc.Twitch,
+			RawMessage: "PRIVMSG #jtv :/w three :D:",
+		},
+	}
+
+	in := make(chan *gbc.PlatformMessage)
+	go func() {
+		defer close(in)
+		for _, mssg := range mssgs {
+			in <- mssg
+		}
+	}()
+
+	daily := dailyLimiter{Limit: 2, Clock: testClock{
+		lock:        &sync.Mutex{},
+		daySwitched: false,
+	}}
+	var receipts []string
+	for mssg := range daily.Apply(in) {
+		receipts = append(receipts, Message(*mssg).Receipt())
+	}
+
+	if !reflect.DeepEqual(receipts, []string{"one", "one", "two"}) {
+		t.Errorf("Should only return messages to <limit> (2) users: %q", receipts)
+	}
+}
+
 // Test daily limiter limits to given amount
 func TestDailyLimiter_resetOnDayChange(t *testing.T) {
 	mssgs := []*gbc.PlatformMessage{
@@ -147,3 +191,24 @@ func TestDailyLimiter_resetOnDayChange(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// Test daily limiter reports discarded messages
+func TestDailyLimiter_dropped(t *testing.T) {
+	in := make(chan *gbc.PlatformMessage)
+	go func() {
+		defer close(in)
+		in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "WHISPER one :D:"}
+		in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "WHISPER two :D:"}
+	}()
+
+	var dropped []string
+	daily := dailyLimiter{Limit: 1, Clock: testClock{lock: &sync.Mutex{}}, Dropped: func(mssg *gbc.PlatformMessage) {
+		dropped = append(dropped, mssg.RawMessage)
+	}}
+	for range daily.Apply(in) {
+	}
+
+	if !reflect.DeepEqual(dropped, []string{"WHISPER two :D:"}) {
+		t.Errorf("Unexpected dropped messages: %q", dropped)
+	}
+}
@@ -0,0 +1,664 @@
+package twitchclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time to wait for twitch to answer a sent message. Messages without answer are reported as
+// sent but not confirmed.
+const deliveryTimeout = 10 * time.Second
+
+// ErrDropped is reported for messages discarded before sending, e.g. because the daily limit of
+// whispered accounts is reached.
+var ErrDropped = errors.New("message dropped before sending")
+
+// ErrExpired is reported for messages which couldn't be sent before their deadline.
+var ErrExpired = errors.New("message expired before sending")
+
+// ErrNonceInUse is returned when sending a message whose `client-nonce` tag is the one of a
+// message whose delivery isn't known yet.
+var ErrNonceInUse = errors.New("client-nonce is used by a pending message")
+
+// DeliveryStatus is the outcome of sending a message.
+type DeliveryStatus int
+
+const (
+	// DeliverySent is reported if the message was sent to twitch.
+	DeliverySent DeliveryStatus = iota
+	// DeliveryRejected is reported if twitch rejected the message with a NOTICE.
+	DeliveryRejected
+	// DeliveryDropped is reported if the message wasn't sent at all.
+	DeliveryDropped
+)
+
+func (status DeliveryStatus) String() string {
+	switch status {
+	case DeliverySent:
+		return "sent"
+	case DeliveryRejected:
+		return "rejected"
+	case DeliveryDropped:
+		return "dropped"
+	default:
+		return fmt.Sprintf("DeliveryStatus(%d)", int(status))
+	}
+}
+
+// Delivery is the result of a message sent with Client.Send.
+type Delivery struct {
+	Status DeliveryStatus
+	// Nonce identifying the message in its `client-nonce` tag.
+	Nonce string
+	// Confirmed is set if twitch answered with USERSTATE. Requires the tags and commands
+	// capabilities, without them messages are reported as sent when written to the connection.
+	Confirmed bool
+	// Err is the reason for rejected and dropped messages. Rejections by twitch are *NoticeError.
+	Err error
+}
+
+// DeadlineTag drops messages sent through the input channel of Client.Connect if they can't be
+// sent before the deadline in its value, formatted as RFC 3339 timestamp. It works like the
+// Deadline option and is removed before the message is sent to twitch.
+const DeadlineTag = "gbc-deadline"
+
+// SendOption configures how a message is sent by Client.Send and the helpers like Client.Say.
+type SendOption func(options *sendOptions)
+
+type sendOptions struct {
+	deadline time.Time
+}
+
+// tag adds the deadline of the options to the message. Messages without deadline are returned unchanged.
+func (options sendOptions) tag(mssg *gbc.PlatformMessage) *gbc.PlatformMessage {
+	if options.deadline.IsZero() {
+		return mssg
+	}
+	return &gbc.PlatformMessage{
+		Platform:   mssg.Platform,
+		RawMessage: withTag(mssg.RawMessage, DeadlineTag, options.deadline.Format(time.RFC3339Nano)),
+	}
+}
+
+func newSendOptions(opts []SendOption) sendOptions {
+	var options sendOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Deadline drops the message if it can't be sent before the given time. Expired messages don't
+// use up a slot of the rate limits and are reported as dropped with ErrExpired.
+func Deadline(deadline time.Time) SendOption {
+	return func(options *sendOptions) {
+		options.deadline = deadline
+	}
+}
+
+// TTL drops the message if it can't be sent within the given duration, e.g. answers to chat
+// commands which are pointless after some time.
+func TTL(ttl time.Duration) SendOption {
+	return Deadline(time.Now().Add(ttl))
+}
+
+// Send passes the message to the limiter like messages sent through the input channel and
+// returns a channel receiving the result of the delivery. Only PRIVMSG messages are supported.
+// The message is identified by its `client-nonce` tag, which is added if missing. Fails with
+// ErrNonceInUse if a message with the same nonce is still pending. Messages split because of
+// their length are reported as sent when all parts were sent.
+func (client *Client) Send(mssg *gbc.PlatformMessage, opts ...SendOption) (<-chan Delivery, error) {
+	return client.SendWithPriority(mssg, PriorityNormal, opts...)
+}
+
+// SendWithPriority sends the message like Send. Messages of higher priority overtake queued
+// messages of lower priority, e.g. to time out spammers while lots of messages are queued.
+func (client *Client) SendWithPriority(mssg *gbc.PlatformMessage, priority Priority, opts ...SendOption) (<-chan Delivery, error) {
+	options := newSendOptions(opts)
+	if mssg.Platform != gbc.Twitch {
+		return nil, fmt.Errorf("can't send message to platform %v", mssg.Platform)
+	}
+	parsed, err := ParseIRC(mssg.RawMessage)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Command != "PRIVMSG" {
+		return nil, fmt.Errorf("expected PRIVMSG but got %s", parsed.Command)
+	}
+	if deadline, ok := deadlineOf(mssg.RawMessage); ok && (options.deadline.IsZero() || deadline.Before(options.deadline)) {
+		// Expired messages are reported by the deliveries, so they have to know the deadline
+		options.deadline = deadline
+	}
+	nonce, ok := parsed.Tag("client-nonce")
+	if !ok || nonce == "" {
+		nonce, err = newNonce()
+		if err != nil {
+			return nil, err
+		}
+		tags := map[string]string{"client-nonce": nonce}
+		for key, value := range parsed.Tags() {
+			if key != "client-nonce" {
+				tags[key] = value
+			}
+		}
+		mssg, err = Outbound{
+			Tags:     tags,
+			Command:  parsed.Command,
+			Params:   parsed.Params,
+			Trailing: parsed.Trailing,
+		}.PlatformMessage()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Every part of split messages carries the deadline, so they expire on their own
+	mssg = options.tag(mssg)
+
+	result, err := client.deliveries.add(nonce, parsed.Channel(), options.deadline)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.enqueue(mssg, priority); err != nil {
+		client.deliveries.remove(nonce)
+		return nil, err
+	}
+	return result, nil
+}
+
+// OnDropped registers a handler which is called for every message discarded before sending which
+// wasn't sent with Client.Send, e.g. messages of the input channel whose DeadlineTag passed with
+// ErrExpired or whispers exceeding the daily limit with ErrDropped. Parts of split messages are
+// reported on their own. Messages left in the pipeline when disconnecting aren't reported.
+// Handlers are called from the goroutines sending messages so they shouldn't block.
+func (client *Client) OnDropped(handler func(mssg *gbc.PlatformMessage, err error)) {
+	client.deliveries.onDropped(handler)
+}
+
+func newNonce() (string, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", fmt.Errorf("failed to create nonce: %w", err)
+	}
+	return hex.EncodeToString(nonce[:]), nil
+}
+
+// nonceOf returns the `client-nonce` tag of the raw message without parsing messages lacking it.
+func nonceOf(raw string) string {
+	if !strings.HasPrefix(raw, "@") || !strings.Contains(raw, "client-nonce=") {
+		return ""
+	}
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		return ""
+	}
+	nonce, _ := mssg.Tag("client-nonce")
+	return nonce
+}
+
+// deadlineOf returns the deadline in the DeadlineTag of the raw message without parsing messages
+// lacking it. Invalid deadlines are ignored.
+func deadlineOf(raw string) (time.Time, bool) {
+	if !strings.HasPrefix(raw, "@") || !strings.Contains(raw, DeadlineTag+"=") {
+		return time.Time{}, false
+	}
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	value, ok := mssg.Tag(DeadlineTag)
+	if !ok {
+		return time.Time{}, false
+	}
+	deadline, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// isTaggedExpired returns if the deadline in the DeadlineTag of the raw message passed.
+func isTaggedExpired(raw string, now time.Time) bool {
+	deadline, ok := deadlineOf(raw)
+	if !ok || now.Before(deadline) {
+		return false
+	}
+	log.Printf("Discarding message which expired at %v: %q", deadline, raw)
+	return true
+}
+
+// withoutDeadline removes the DeadlineTag from the raw message, as it is only read by the client.
+func withoutDeadline(raw string) string {
+	return withoutTag(raw, DeadlineTag)
+}
+
+// splitTags splits the raw message into its tags without the leading '@' and the rest of the
+// line starting with the space after them. Returns false if the message doesn't have tags.
+func splitTags(raw string) (tags, rest string, ok bool) {
+	if !strings.HasPrefix(raw, "@") {
+		return "", raw, false
+	}
+	end := strings.IndexByte(raw, ' ')
+	if end == -1 {
+		return raw[1:], "", true
+	}
+	return raw[1:end], raw[end:], true
+}
+
+// withoutTag removes all occurrences of the tag from the raw message. The rest of the line is
+// left unchanged.
+func withoutTag(raw, key string) string {
+	tags, rest, ok := splitTags(raw)
+	if !ok || !strings.Contains(tags, key) {
+		return raw
+	}
+	kept := make([]string, 0, strings.Count(tags, ";")+1)
+	removed := false
+	for tags != "" {
+		var pair string
+		pair, tags = nextTag(tags)
+		if pair == key || strings.HasPrefix(pair, key+"=") {
+			removed = true
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	if !removed {
+		return raw
+	}
+	if len(kept) == 0 {
+		return strings.TrimPrefix(rest, " ")
+	}
+	return "@" + strings.Join(kept, ";") + rest
+}
+
+// withTag sets the tag of the raw message, replacing a present value. The rest of the line is
+// left unchanged.
+func withTag(raw, key, value string) string {
+	raw = withoutTag(raw, key)
+	pair := key
+	if value != "" {
+		pair += "=" + escapeTagValue(value)
+	}
+	if tags, rest, ok := splitTags(raw); ok {
+		return "@" + pair + ";" + tags + rest
+	}
+	return "@" + pair + " " + raw
+}
+
+// channelOf returns the channel of chat messages without parsing other messages.
+func channelOf(raw string) string {
+	if command, _ := peekCommand(raw); command != "PRIVMSG" {
+		return ""
+	}
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		return ""
+	}
+	return mssg.Channel()
+}
+
+// chatRejections contains the `msg-id` of NOTICE messages rejecting a chat message. Other notices,
+// e.g. `msg_channel_suspended` which is sent for failed JOINs as well, don't answer a chat message.
+var chatRejections = map[string]bool{
+	"msg_banned":                         true,
+	"msg_banned_email_alias":             true,
+	"msg_bad_characters":                 true,
+	"msg_channel_blocked":                true,
+	"msg_duplicate":                      true,
+	"msg_emoteonly":                      true,
+	"msg_followersonly":                  true,
+	"msg_followersonly_followed":         true,
+	"msg_followersonly_zero":             true,
+	"msg_r9k":                            true,
+	"msg_ratelimit":                      true,
+	"msg_rejected":                       true,
+	"msg_rejected_mandatory":             true,
+	"msg_requires_verified_phone_number": true,
+	"msg_slowmode":                       true,
+	"msg_subsonly":                       true,
+	"msg_suspended":                      true,
+	"msg_timedout":                       true,
+	"msg_verified_email":                 true,
+}
+
+type pendingDelivery struct {
+	nonce   string
+	channel string
+	// parts not yet reported as sent. Increased if the message is split.
+	parts int
+	// inFlight is the number of parts written to the connection and awaiting an answer of twitch.
+	inFlight int
+	// unconfirmed is set if a part was reported as sent because twitch didn't answer it in time.
+	unconfirmed bool
+	// deadline after which the message isn't sent anymore. Zero if the message doesn't expire.
+	deadline time.Time
+	result   chan Delivery
+	// timer reports the parts in flight as sent if twitch doesn't answer them. Only set while a
+	// part is in flight.
+	timer *time.Timer
+}
+
+// awaitedAnswer is a message sent to a channel waiting for an answer of twitch.
+type awaitedAnswer struct {
+	// nonce of the message. Empty if the message doesn't have one.
+	nonce string
+}
+
+// deliveries keeps track of messages sent with Client.Send until their delivery is known.
+type deliveries struct {
+	lock    sync.Mutex
+	pending map[string]*pendingDelivery
+	// awaiting contains the messages sent to a channel waiting for an answer of twitch in the
+	// order they were sent. Contains all chat messages, not only the ones sent with Client.Send,
+	// so answers without nonce are matched with the right message.
+	awaiting map[string][]*awaitedAnswer
+	// confirm is set if twitch answers sent messages, which requires the tags and commands capabilities.
+	confirm bool
+	timeout time.Duration
+	// dropHandlers are called with dropped messages which weren't sent with Client.Send.
+	dropHandlers []func(mssg *gbc.PlatformMessage, err error)
+}
+
+func newDeliveries() *deliveries {
+	return &deliveries{
+		pending:  make(map[string]*pendingDelivery),
+		awaiting: make(map[string][]*awaitedAnswer),
+		timeout:  deliveryTimeout,
+	}
+}
+
+// add tracks the delivery of the message. Fails if the nonce is still used by another message.
+func (dels *deliveries) add(nonce, channel string, deadline time.Time) (<-chan Delivery, error) {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	if _, ok := dels.pending[nonce]; ok {
+		return nil, ErrNonceInUse
+	}
+	pending := &pendingDelivery{
+		nonce:    nonce,
+		channel:  channel,
+		parts:    1,
+		deadline: deadline,
+		result:   make(chan Delivery, 1),
+	}
+	dels.pending[nonce] = pending
+	return pending.result, nil
+}
+
+func (dels *deliveries) remove(nonce string) {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	delete(dels.pending, nonce)
+}
+
+// split records that the message was split into the given number of parts.
+func (dels *deliveries) split(nonce string, parts int) {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	if pending, ok := dels.pending[nonce]; ok {
+		pending.parts += parts - 1
+	}
+}
+
+// answerExpected returns if twitch answers the message.
+func (dels *deliveries) answerExpected(whisper bool) bool {
+	// Whispers aren't answered by twitch
+	return dels.confirm && !whisper
+}
+
+// sending records that a part of the message is about to be written to the channel, so answers
+// of twitch can be matched with it. Has to be called for all chat messages, as twitch answers
+// messages not sent with Client.Send as well.
+func (dels *deliveries) sending(nonce, channel string, whisper bool) {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	if channel == "" || !dels.answerExpected(whisper) {
+		return
+	}
+	awaited := &awaitedAnswer{nonce: nonce}
+	dels.awaiting[channel] = append(dels.awaiting[channel], awaited)
+	pending, ok := dels.pending[nonce]
+	if nonce == "" || !ok {
+		// Forget the message if twitch doesn't answer it
+		time.AfterFunc(dels.timeout, func() {
+			dels.lock.Lock()
+			defer dels.lock.Unlock()
+			dels.forget(channel, awaited)
+		})
+		return
+	}
+	// Parts of split messages are limited on their own, so the timeout starts with the last part
+	pending.inFlight++
+	if pending.timer != nil {
+		pending.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(dels.timeout, func() {
+		dels.lock.Lock()
+		defer dels.lock.Unlock()
+		if dels.pending[nonce] != pending || pending.timer != timer {
+			return
+		}
+		// Only the parts in flight are reported as sent, later parts may still be queued
+		pending.timer = nil
+		pending.unconfirmed = true
+		pending.parts -= pending.inFlight
+		pending.inFlight = 0
+		if pending.parts <= 0 {
+			dels.resolve(pending, Delivery{Status: DeliverySent})
+		}
+	})
+	pending.timer = timer
+}
+
+// written records that a part of the message was written to the connection. Messages which aren't
+// answered by twitch are reported as sent.
+func (dels *deliveries) written(nonce string, whisper bool) {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	pending, ok := dels.pending[nonce]
+	if ok && !dels.answerExpected(whisper) {
+		dels.partSent(pending, false)
+	}
+}
+
+// expired reports a message discarded because its deadline passed. Messages sent with Client.Send
+// are reported by their delivery, all others to the handlers registered with Client.OnDropped.
+// Every part carries the deadline in its tag, so the caller checks each part on its own.
+func (dels *deliveries) expired(mssg *gbc.PlatformMessage, now time.Time) {
+	nonce := nonceOf(mssg.RawMessage)
+	dels.lock.Lock()
+	if pending, ok := dels.pending[nonce]; ok {
+		// Parts of an earlier message using the same nonce don't expire this one
+		if !pending.deadline.IsZero() && !now.Before(pending.deadline) {
+			dels.resolve(pending, Delivery{Status: DeliveryDropped, Err: ErrExpired})
+		}
+		dels.lock.Unlock()
+		return
+	}
+	dels.lock.Unlock()
+	dels.report(mssg, ErrExpired)
+}
+
+// dropped reports the message as dropped. Returns false if the message isn't tracked.
+func (dels *deliveries) dropped(nonce string, err error) bool {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	pending, ok := dels.pending[nonce]
+	if ok {
+		dels.resolve(pending, Delivery{Status: DeliveryDropped, Err: err})
+	}
+	return ok
+}
+
+// drop reports a message discarded before sending like dropped. Messages which aren't tracked
+// are reported to the handlers registered with Client.OnDropped.
+func (dels *deliveries) drop(mssg *gbc.PlatformMessage, err error) {
+	if !dels.dropped(nonceOf(mssg.RawMessage), err) {
+		dels.report(mssg, err)
+	}
+}
+
+// report calls the handlers registered with Client.OnDropped.
+func (dels *deliveries) report(mssg *gbc.PlatformMessage, err error) {
+	dels.lock.Lock()
+	handlers := dels.dropHandlers
+	dels.lock.Unlock()
+	for _, handler := range handlers {
+		handler(mssg, err)
+	}
+}
+
+func (dels *deliveries) onDropped(handler func(mssg *gbc.PlatformMessage, err error)) {
+	dels.lock.Lock()
+	dels.dropHandlers = append(dels.dropHandlers, handler)
+	dels.lock.Unlock()
+}
+
+// disconnected reports all pending messages after disconnecting. Messages whose remaining parts
+// were all written are reported as sent without confirmation, all others as dropped. Returns the nonces of the
+// reported messages.
+func (dels *deliveries) disconnected() []string {
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	nonces := make([]string, 0, len(dels.pending))
+	for _, pending := range dels.pending {
+		nonces = append(nonces, pending.nonce)
+		if pending.inFlight > 0 && pending.inFlight == pending.parts {
+			dels.resolve(pending, Delivery{Status: DeliverySent})
+		} else {
+			dels.resolve(pending, Delivery{Status: DeliveryDropped, Err: ErrDisconnected})
+		}
+	}
+	// Messages of the closed connection aren't answered anymore
+	dels.awaiting = make(map[string][]*awaitedAnswer)
+	return nonces
+}
+
+// answered matches USERSTATE and NOTICE messages of twitch with the sent messages. Only notices
+// rejecting chat messages are matched. Messages are matched by their nonce. As twitch doesn't always echo it, answers without nonce are matched
+// with the oldest message sent to the channel waiting for an answer.
+func (dels *deliveries) answered(mssg *IRCMessage) {
+	var rejection error
+	switch mssg.Command {
+	case "USERSTATE":
+		// USERSTATE is also sent after joining a channel. Only the ones answering a message
+		// contain its id or nonce.
+		if _, ok := mssg.Tag("id"); !ok {
+			if _, ok := mssg.Tag("client-nonce"); !ok {
+				return
+			}
+		}
+	case "NOTICE":
+		notice, err := NewNotice(mssg)
+		if err != nil || !chatRejections[notice.MsgID] {
+			return
+		}
+		rejection = notice.Err()
+	default:
+		return
+	}
+
+	dels.lock.Lock()
+	defer dels.lock.Unlock()
+	channel := mssg.Channel()
+	nonce, _ := mssg.Tag("client-nonce")
+	if nonce == "" {
+		if len(dels.awaiting[channel]) == 0 {
+			return
+		}
+		// The oldest message may be one not sent with Client.Send, which isn't pending
+		oldest := dels.awaiting[channel][0]
+		dels.forget(channel, oldest)
+		nonce = oldest.nonce
+	} else {
+		dels.answer(channel, nonce, false)
+	}
+	if nonce == "" {
+		return
+	}
+
+	pending, ok := dels.pending[nonce]
+	if !ok {
+		return
+	}
+	if rejection != nil {
+		dels.resolve(pending, Delivery{Status: DeliveryRejected, Err: rejection})
+	} else {
+		dels.partAnswered(pending)
+	}
+}
+
+// answer removes the nonce from the messages awaiting an answer. Removes only the first
+// occurrence unless all is set.
+func (dels *deliveries) answer(channel, nonce string, all bool) {
+	removed := false
+	dels.removeAwaiting(channel, func(awaited *awaitedAnswer) bool {
+		if awaited.nonce == nonce && (all || !removed) {
+			removed = true
+			return true
+		}
+		return false
+	})
+}
+
+// forget removes the message from the messages awaiting an answer.
+func (dels *deliveries) forget(channel string, awaited *awaitedAnswer) {
+	dels.removeAwaiting(channel, func(candidate *awaitedAnswer) bool {
+		return candidate == awaited
+	})
+}
+
+// removeAwaiting removes the messages awaiting an answer in the channel matching the filter.
+func (dels *deliveries) removeAwaiting(channel string, matches func(*awaitedAnswer) bool) {
+	var awaiting []*awaitedAnswer
+	for _, candidate := range dels.awaiting[channel] {
+		if !matches(candidate) {
+			awaiting = append(awaiting, candidate)
+		}
+	}
+	if len(awaiting) == 0 {
+		delete(dels.awaiting, channel)
+	} else {
+		dels.awaiting[channel] = awaiting
+	}
+}
+
+// partAnswered reports a part in flight as sent. Stops the timeout if no other part is in flight,
+// as the next part of split messages may wait in the limiter for longer than the timeout.
+func (dels *deliveries) partAnswered(pending *pendingDelivery) {
+	if pending.inFlight > 0 {
+		pending.inFlight--
+	}
+	if pending.inFlight == 0 && pending.timer != nil {
+		pending.timer.Stop()
+		pending.timer = nil
+	}
+	dels.partSent(pending, true)
+}
+
+func (dels *deliveries) partSent(pending *pendingDelivery, confirmed bool) {
+	pending.parts--
+	if pending.parts <= 0 {
+		dels.resolve(pending, Delivery{Status: DeliverySent, Confirmed: confirmed && !pending.unconfirmed})
+	}
+}
+
+// resolve reports the result and forgets the message. Has to be called while holding the lock.
+func (dels *deliveries) resolve(pending *pendingDelivery, result Delivery) {
+	if pending.timer != nil {
+		pending.timer.Stop()
+	}
+	delete(dels.pending, pending.nonce)
+	dels.answer(pending.channel, pending.nonce, true)
+	result.Nonce = pending.nonce
+	pending.result <- result
+}
@@ -0,0 +1,352 @@
+package twitchclient
+
+import (
+	"errors"
+	"github.com/MoBlaa/gbc"
+	"testing"
+	"time"
+)
+
+// awaitDelivery waits for the result of a sent message.
+func awaitDelivery(t *testing.T, result <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case delivery := <-result:
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for delivery")
+		return Delivery{}
+	}
+}
+
+func answer(t *testing.T, dels *deliveries, raw string) {
+	t.Helper()
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	dels.answered(mssg)
+}
+
+func TestDeliveries_confirmed(t *testing.T) {
+	dels := newDeliveries()
+	dels.confirm = true
+	result, _ := dels.add("abc", "bot", time.Time{})
+	dels.sending("abc", "bot", false)
+	dels.written("abc", false)
+
+	// USERSTATE sent after joining doesn't answer a message
+	answer(t, dels, "@color=;display-name=bot :tmi.twitch.tv USERSTATE #bot")
+	select {
+	case delivery := <-result:
+		t.Fatalf("Unexpected delivery: %+v", delivery)
+	default:
+	}
+
+	answer(t, dels, "@client-nonce=abc;display-name=bot :tmi.twitch.tv USERSTATE #bot")
+	delivery := awaitDelivery(t, result)
+	if delivery.Status != DeliverySent || !delivery.Confirmed || delivery.Nonce != "abc" {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveries_rejectedWithoutNonce(t *testing.T) {
+	dels := newDeliveries()
+	dels.confirm = true
+	first, _ := dels.add("first", "bot", time.Time{})
+	second, _ := dels.add("second", "bot", time.Time{})
+	dels.sending("first", "bot", false)
+	dels.written("first", false)
+	dels.sending("second", "bot", false)
+	dels.written("second", false)
+
+	// Answers without nonce are matched in the order the messages were sent
+	answer(t, dels, "@msg-id=msg_duplicate :tmi.twitch.tv NOTICE #bot :Your message is identical to the one you sent less than 30 seconds ago.")
+	delivery := awaitDelivery(t, first)
+	if delivery.Status != DeliveryRejected || !errors.Is(delivery.Err, ErrDuplicate) {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+	var noticeErr *NoticeError
+	if !errors.As(delivery.Err, &noticeErr) || noticeErr.MsgID != "msg_duplicate" {
+		t.Errorf("Expected NoticeError but got: %v", delivery.Err)
+	}
+
+	// Informational notices and notices answering other commands don't answer messages
+	answer(t, dels, "@msg-id=host_on :tmi.twitch.tv NOTICE #bot :Now hosting someone.")
+	answer(t, dels, "@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #bot :This channel does not exist or has been suspended.")
+	answer(t, dels, "@id=msg-id;display-name=bot :tmi.twitch.tv USERSTATE #bot")
+	delivery = awaitDelivery(t, second)
+	if delivery.Status != DeliverySent || !delivery.Confirmed || delivery.Nonce != "second" {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveries_split(t *testing.T) {
+	dels := newDeliveries()
+	dels.confirm = true
+	result, _ := dels.add("abc", "bot", time.Time{})
+	dels.split("abc", 2)
+
+	dels.sending("abc", "bot", false)
+	dels.written("abc", false)
+	answer(t, dels, "@client-nonce=abc :tmi.twitch.tv USERSTATE #bot")
+	select {
+	case delivery := <-result:
+		t.Fatalf("Delivery reported before all parts were sent: %+v", delivery)
+	default:
+	}
+
+	dels.sending("abc", "bot", false)
+	dels.written("abc", false)
+	answer(t, dels, "@client-nonce=abc :tmi.twitch.tv USERSTATE #bot")
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliverySent {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveries_splitQueued(t *testing.T) {
+	dels := newDeliveries()
+	dels.confirm = true
+	dels.timeout = 10 * time.Millisecond
+	result, _ := dels.add("abc", "bot", time.Time{})
+	dels.split("abc", 2)
+
+	// The second part may wait in the limiter for longer than the timeout
+	dels.sending("abc", "bot", false)
+	dels.written("abc", false)
+	answer(t, dels, "@client-nonce=abc :tmi.twitch.tv USERSTATE #bot")
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case delivery := <-result:
+		t.Fatalf("Delivery reported before all parts were sent: %+v", delivery)
+	default:
+	}
+
+	// Parts still queued after disconnecting weren't sent
+	dels.disconnected()
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliveryDropped || delivery.Err != ErrDisconnected {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	// Unanswered parts are reported as sent, but the message only after its last part
+	result, _ = dels.add("def", "bot", time.Time{})
+	dels.split("def", 2)
+	dels.sending("def", "bot", false)
+	dels.written("def", false)
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case delivery := <-result:
+		t.Fatalf("Delivery reported before all parts were sent: %+v", delivery)
+	default:
+	}
+	dels.sending("def", "bot", false)
+	dels.written("def", false)
+	answer(t, dels, "@client-nonce=def :tmi.twitch.tv USERSTATE #bot")
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliverySent || delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveries_untrackedMessages(t *testing.T) {
+	dels := newDeliveries()
+	dels.confirm = true
+	dels.timeout = 10 * time.Millisecond
+
+	// Messages not sent with Client.Send are answered as well
+	dels.sending("", "bot", false)
+	tracked, _ := dels.add("tracked", "bot", time.Time{})
+	dels.sending("tracked", "bot", false)
+	dels.written("tracked", false)
+
+	answer(t, dels, "@msg-id=msg_duplicate :tmi.twitch.tv NOTICE #bot :Your message is identical to the one you sent less than 30 seconds ago.")
+	select {
+	case delivery := <-tracked:
+		t.Fatalf("Answer of another message reported for tracked one: %+v", delivery)
+	default:
+	}
+	answer(t, dels, "@id=1;display-name=bot :tmi.twitch.tv USERSTATE #bot")
+	if delivery := awaitDelivery(t, tracked); delivery.Status != DeliverySent || !delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	// Unanswered messages are forgotten after the timeout
+	dels.sending("", "bot", false)
+	time.Sleep(50 * time.Millisecond)
+	dels.lock.Lock()
+	awaiting := len(dels.awaiting["bot"])
+	dels.lock.Unlock()
+	if awaiting != 0 {
+		t.Errorf("Unanswered message wasn't forgotten: %d awaiting", awaiting)
+	}
+}
+
+func TestDeliveries_unconfirmed(t *testing.T) {
+	dels := newDeliveries()
+	dels.confirm = true
+	dels.timeout = 10 * time.Millisecond
+
+	timedOut, _ := dels.add("timeout", "bot", time.Time{})
+	dels.sending("timeout", "bot", false)
+	dels.written("timeout", false)
+	if delivery := awaitDelivery(t, timedOut); delivery.Status != DeliverySent || delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	// Twitch doesn't answer whispers
+	whisper, _ := dels.add("whisper", "jtv", time.Time{})
+	dels.sending("whisper", "jtv", true)
+	dels.written("whisper", true)
+	if delivery := awaitDelivery(t, whisper); delivery.Status != DeliverySent || delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveries_dropped(t *testing.T) {
+	dels := newDeliveries()
+	dropped, _ := dels.add("dropped", "jtv", time.Time{})
+	dels.dropped("dropped", ErrDropped)
+	if delivery := awaitDelivery(t, dropped); delivery.Status != DeliveryDropped || delivery.Err != ErrDropped {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	dels.confirm = true
+	written, _ := dels.add("written", "bot", time.Time{})
+	queued, _ := dels.add("queued", "bot", time.Time{})
+	dels.sending("written", "bot", false)
+	dels.written("written", false)
+	dels.disconnected()
+	if delivery := awaitDelivery(t, written); delivery.Status != DeliverySent || delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+	if delivery := awaitDelivery(t, queued); delivery.Status != DeliveryDropped || delivery.Err != ErrDisconnected {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDeliveries_expired(t *testing.T) {
+	dels := newDeliveries()
+	now := time.Now()
+	var reported []string
+	dels.onDropped(func(mssg *gbc.PlatformMessage, err error) {
+		if err == ErrExpired {
+			reported = append(reported, mssg.RawMessage)
+		}
+	})
+
+	var options sendOptions
+	TTL(time.Minute)(&options)
+	if options.deadline.Before(now.Add(time.Minute)) || options.deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("Unexpected deadline for TTL: %v", options.deadline)
+	}
+
+	// Each part of a split message expires on its own, the delivery is reported once
+	split, _ := dels.add("split", "bot", now)
+	dels.split("split", 3)
+	part := &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=split PRIVMSG #bot :part"}
+	dels.expired(part, now)
+	delivery := awaitDelivery(t, split)
+	if delivery.Status != DeliveryDropped || delivery.Err != ErrExpired || delivery.Nonce != "split" {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	// Parts of an expired message don't expire a new message using the nonce
+	reused, _ := dels.add("split", "bot", now.Add(time.Hour))
+	dels.expired(part, now)
+	select {
+	case delivery := <-reused:
+		t.Fatalf("Unexpected delivery: %+v", delivery)
+	default:
+	}
+	dels.remove("split")
+
+	// Messages not sent with Client.Send are reported to the handlers
+	dels.expired(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :raw"}, now)
+	if len(reported) != 1 || reported[0] != "PRIVMSG #bot :raw" {
+		t.Errorf("Unexpected reported messages: %q", reported)
+	}
+}
+
+func TestWithoutDeadline(t *testing.T) {
+	tests := map[string]string{
+		"PRIVMSG #c :hi":                              "PRIVMSG #c :hi",
+		"@gbc-deadline=x PRIVMSG #c :":                "PRIVMSG #c :",
+		"@b=2;gbc-deadline=x;a=1 PRIVMSG #c :a  b":    "@b=2;a=1 PRIVMSG #c :a  b",
+		"@gbc-deadline-other=1 PRIVMSG #c :hi":        "@gbc-deadline-other=1 PRIVMSG #c :hi",
+		"@gbc-deadline;gbc-deadline=y PRIVMSG #c :hi": "PRIVMSG #c :hi",
+	}
+	for raw, expected := range tests {
+		if actual := withoutDeadline(raw); actual != expected {
+			t.Errorf("Unexpected result for %q :: expected: %q, actual: %q", raw, expected, actual)
+		}
+	}
+
+	if actual := withTag("@b=2 PRIVMSG #c :", "gbc-deadline", "x y"); actual != `@gbc-deadline=x\sy;b=2 PRIVMSG #c :` {
+		t.Errorf("Unexpected tagged message: %q", actual)
+	}
+	if actual := withTag("PRIVMSG #c :", "a", "1"); actual != "@a=1 PRIVMSG #c :" {
+		t.Errorf("Unexpected tagged message: %q", actual)
+	}
+}
+
+func TestClient_Send(t *testing.T) {
+	client, in, out, conn := connectFake(t, WithTags(), WithCommands())
+	defer close(in)
+	go func() {
+		for range out {
+		}
+	}()
+
+	result, err := client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=abc PRIVMSG #bot :hi"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	conn.expect(t, "@client-nonce=abc PRIVMSG #bot :hi")
+	conn.send(t, "@client-nonce=abc;display-name=bot;id=1 :tmi.twitch.tv USERSTATE #bot")
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliverySent || !delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	// A nonce is added to messages without one
+	result, err = client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :hi"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	line := <-conn.lines
+	nonce := nonceOf(line)
+	if nonce == "" {
+		t.Fatalf("Expected nonce to be added: %q", line)
+	}
+	conn.send(t, "@msg-id=msg_slowmode :tmi.twitch.tv NOTICE #bot :This room is in slow mode.")
+	delivery := awaitDelivery(t, result)
+	if delivery.Status != DeliveryRejected || delivery.Nonce != nonce || !errors.Is(delivery.Err, ErrSlowMode) {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	// A nonce can't be used by two pending messages, as their deliveries couldn't be told apart
+	result, err = client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=dup PRIVMSG #bot :first"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if _, err := client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=dup PRIVMSG #bot :second"}); !errors.Is(err, ErrNonceInUse) {
+		t.Errorf("Expected ErrNonceInUse but got %v", err)
+	}
+	conn.expect(t, "@client-nonce=dup PRIVMSG #bot :first")
+	conn.send(t, "@client-nonce=dup;display-name=bot;id=2 :tmi.twitch.tv USERSTATE #bot")
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliverySent || !delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+	// The nonce can be used again after the delivery is known
+	result, err = client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=dup PRIVMSG #bot :third"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	conn.expect(t, "@client-nonce=dup PRIVMSG #bot :third")
+	conn.send(t, "@client-nonce=dup;display-name=bot;id=3 :tmi.twitch.tv USERSTATE #bot")
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliverySent || !delivery.Confirmed {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+
+	if _, err := client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "JOIN #other"}); err == nil {
+		t.Error("Expected error for message other than PRIVMSG")
+	}
+}
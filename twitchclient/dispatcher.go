@@ -0,0 +1,266 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Size of the queue of each channel. If handlers of a channel can't keep up, reading further
+// messages is blocked until the queue has room again.
+const dispatchQueueSize = 64
+
+// Dispatcher converts messages received from twitch into events and calls the handlers
+// registered for them. Events of one channel are delivered in the order they were received,
+// while events of different channels are handled concurrently.
+type Dispatcher struct {
+	lock     sync.RWMutex
+	nextID   int
+	handlers []registeredHandler
+}
+
+type registeredHandler struct {
+	id     int
+	handle func(Event)
+}
+
+// NewDispatcher creates a Dispatcher without handlers.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Run reads all messages from the channel returned by `Client.Connect` and dispatches them to
+// the registered handlers. Blocks until the channel is closed and all events are handled.
+func (disp *Dispatcher) Run(out <-chan *gbc.PlatformMessage) {
+	queues := newDispatchQueues(disp)
+	defer queues.close()
+
+	// Login of the bot, which twitch sends with the welcome message
+	var login string
+	for mssg := range out {
+		if mssg.Platform != gbc.Twitch {
+			continue
+		}
+		parsed, err := Message(*mssg).Parse()
+		if err != nil {
+			log.Printf("failed to dispatch message %q: %v", mssg.RawMessage, err)
+			continue
+		}
+		event, err := parsed.Event()
+		if err != nil {
+			// Handlers of the command still receive the message, e.g. if only a tag is malformed
+			log.Printf("failed to convert message %q into event: %v", mssg.RawMessage, err)
+			event = parsed
+		}
+
+		channel := parsed.Channel()
+		queues.dispatch(channel, event)
+		switch {
+		case parsed.Command == "001":
+			login = parsed.Param(0)
+		case parsed.Command == "PART" && login != "" && strings.EqualFold(parsed.Prefix.Nick, login):
+			// No more events are received for the channel until it is joined again
+			queues.remove(channel)
+		}
+	}
+}
+
+// dispatchQueues hands events to one goroutine per channel, so events of a channel are handled in
+// the order they were received. Only used by the goroutine of Dispatcher.Run.
+type dispatchQueues struct {
+	disp   *Dispatcher
+	wg     sync.WaitGroup
+	queues map[string]*dispatchQueue
+	// removed contains the queues of parted channels which may still be handling events, so
+	// events after joining again are handled after them.
+	removed map[string]*dispatchQueue
+}
+
+type dispatchQueue struct {
+	events chan Event
+	// done is closed when all events of the queue were handled.
+	done chan struct{}
+}
+
+func newDispatchQueues(disp *Dispatcher) *dispatchQueues {
+	return &dispatchQueues{
+		disp:    disp,
+		queues:  make(map[string]*dispatchQueue),
+		removed: make(map[string]*dispatchQueue),
+	}
+}
+
+// dispatch passes the event to the queue of the channel, which is started if necessary.
+func (queues *dispatchQueues) dispatch(channel string, event Event) {
+	queue, ok := queues.queues[channel]
+	if !ok {
+		previous := queues.removed[channel]
+		delete(queues.removed, channel)
+		queue = &dispatchQueue{
+			events: make(chan Event, dispatchQueueSize),
+			done:   make(chan struct{}),
+		}
+		queues.queues[channel] = queue
+		queues.wg.Add(1)
+		go func() {
+			defer queues.wg.Done()
+			defer close(queue.done)
+			if previous != nil {
+				<-previous.done
+			}
+			for event := range queue.events {
+				queues.disp.Dispatch(event)
+			}
+		}()
+	}
+	queue.events <- event
+}
+
+// remove stops the goroutine of the channel after the queued events were handled.
+func (queues *dispatchQueues) remove(channel string) {
+	queue, ok := queues.queues[channel]
+	if !ok {
+		return
+	}
+	close(queue.events)
+	delete(queues.queues, channel)
+	queues.removed[channel] = queue
+	// Forget queues which finished in the meantime
+	for channel, removed := range queues.removed {
+		select {
+		case <-removed.done:
+			delete(queues.removed, channel)
+		default:
+		}
+	}
+}
+
+// close stops all goroutines and waits until all events are handled.
+func (queues *dispatchQueues) close() {
+	for channel := range queues.queues {
+		queues.remove(channel)
+	}
+	queues.wg.Wait()
+}
+
+// Dispatch calls all handlers registered for the event in the current goroutine.
+// Panics of handlers are recovered and logged.
+func (disp *Dispatcher) Dispatch(event Event) {
+	disp.lock.RLock()
+	handlers := disp.handlers
+	disp.lock.RUnlock()
+
+	for _, handler := range handlers {
+		call(handler.handle, event)
+	}
+}
+
+func call(handle func(Event), event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("handler panicked for message %q: %v", event.IRC().Raw, r)
+		}
+	}()
+	handle(event)
+}
+
+// register adds the handler and returns a function removing it again.
+func (disp *Dispatcher) register(handle func(Event)) (remove func()) {
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+	id := disp.nextID
+	disp.nextID++
+	// Copy on write as Dispatch iterates over the slice without holding the lock
+	handlers := make([]registeredHandler, len(disp.handlers), len(disp.handlers)+1)
+	copy(handlers, disp.handlers)
+	disp.handlers = append(handlers, registeredHandler{id: id, handle: handle})
+
+	return func() {
+		disp.lock.Lock()
+		defer disp.lock.Unlock()
+		handlers := make([]registeredHandler, 0, len(disp.handlers))
+		for _, handler := range disp.handlers {
+			if handler.id != id {
+				handlers = append(handlers, handler)
+			}
+		}
+		disp.handlers = handlers
+	}
+}
+
+// OnEvent registers a handler called for every event. Returns a function removing the handler.
+func (disp *Dispatcher) OnEvent(handler func(Event)) (remove func()) {
+	return disp.register(handler)
+}
+
+// OnCommand registers a handler called for every message with the given command, e.g. "JOIN".
+// Returns a function removing the handler.
+func (disp *Dispatcher) OnCommand(command string, handler func(Event)) (remove func()) {
+	return disp.register(func(event Event) {
+		if event.IRC().Command == command {
+			handler(event)
+		}
+	})
+}
+
+// OnPrivmsg registers a handler called for every chat message. Returns a function removing the handler.
+func (disp *Dispatcher) OnPrivmsg(handler func(*ChatMessage)) (remove func()) {
+	return disp.register(func(event Event) {
+		if chat, ok := event.(*ChatMessage); ok {
+			handler(chat)
+		}
+	})
+}
+
+// OnWhisper registers a handler called for every whisper. Returns a function removing the handler.
+func (disp *Dispatcher) OnWhisper(handler func(*Whisper)) (remove func()) {
+	return disp.register(func(event Event) {
+		if whisper, ok := event.(*Whisper); ok {
+			handler(whisper)
+		}
+	})
+}
+
+// OnUserNotice registers a handler called for every USERNOTICE. Use a type switch to handle the
+// typed notices like *Sub or *Raid. Returns a function removing the handler.
+func (disp *Dispatcher) OnUserNotice(handler func(UserNoticeEvent)) (remove func()) {
+	return disp.register(func(event Event) {
+		if notice, ok := event.(UserNoticeEvent); ok {
+			handler(notice)
+		}
+	})
+}
+
+// OnClearChat registers a handler called for every CLEARCHAT. The event is either a *ChatCleared,
+// *UserBanned or *UserTimedOut. Returns a function removing the handler.
+func (disp *Dispatcher) OnClearChat(handler func(Event)) (remove func()) {
+	return disp.OnCommand("CLEARCHAT", handler)
+}
+
+// OnClearMsg registers a handler called for every deleted message. Returns a function removing the handler.
+func (disp *Dispatcher) OnClearMsg(handler func(*MessageDeleted)) (remove func()) {
+	return disp.register(func(event Event) {
+		if deleted, ok := event.(*MessageDeleted); ok {
+			handler(deleted)
+		}
+	})
+}
+
+// OnRoomState registers a handler called for every ROOMSTATE. Returns a function removing the handler.
+func (disp *Dispatcher) OnRoomState(handler func(*RoomStateUpdate)) (remove func()) {
+	return disp.register(func(event Event) {
+		if update, ok := event.(*RoomStateUpdate); ok {
+			handler(update)
+		}
+	})
+}
+
+// OnNotice registers a handler called for every NOTICE. Returns a function removing the handler.
+func (disp *Dispatcher) OnNotice(handler func(*Notice)) (remove func()) {
+	return disp.register(func(event Event) {
+		if notice, ok := event.(*Notice); ok {
+			handler(notice)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package twitchclient
+
+import (
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"sync"
+	"testing"
+)
+
+func twitchMessages(raws ...string) <-chan *gbc.PlatformMessage {
+	out := make(chan *gbc.PlatformMessage, len(raws))
+	for _, raw := range raws {
+		out <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: raw}
+	}
+	close(out)
+	return out
+}
+
+func TestDispatcher_typedHandlers(t *testing.T) {
+	disp := NewDispatcher()
+	var lock sync.Mutex
+	var received []string
+	record := func(name string) {
+		lock.Lock()
+		received = append(received, name)
+		lock.Unlock()
+	}
+	disp.OnPrivmsg(func(chat *ChatMessage) { record("privmsg:" + chat.Text) })
+	disp.OnWhisper(func(whisper *Whisper) { record("whisper:" + whisper.Text) })
+	disp.OnUserNotice(func(notice UserNoticeEvent) { record(fmt.Sprintf("usernotice:%T", notice)) })
+	disp.OnClearChat(func(event Event) { record(fmt.Sprintf("clearchat:%T", event)) })
+	disp.OnRoomState(func(update *RoomStateUpdate) { record("roomstate:" + update.Channel) })
+	disp.OnCommand("PING", func(event Event) { record("ping") })
+
+	disp.Run(twitchMessages(
+		":user!user@user.tmi.twitch.tv PRIVMSG #channel :hello",
+		":user!user@user.tmi.twitch.tv WHISPER bot :psst",
+		"@msg-id=raid :tmi.twitch.tv USERNOTICE #channel",
+		"@ban-duration=10 :tmi.twitch.tv CLEARCHAT #channel :user",
+		"@slow=10 :tmi.twitch.tv ROOMSTATE #channel",
+		"PING :tmi.twitch.tv",
+	))
+
+	expected := map[string]bool{
+		"privmsg:hello":                        true,
+		"whisper:psst":                         true,
+		"usernotice:*twitchclient.Raid":        true,
+		"clearchat:*twitchclient.UserTimedOut": true,
+		"roomstate:channel":                    true,
+		"ping":                                 true,
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("Unexpected events: %v", received)
+	}
+	for _, name := range received {
+		if !expected[name] {
+			t.Errorf("Unexpected event: %s", name)
+		}
+	}
+}
+
+func TestDispatcher_orderedPerChannel(t *testing.T) {
+	var raws []string
+	for i := 0; i < 200; i++ {
+		raws = append(raws, fmt.Sprintf(":user!user@user.tmi.twitch.tv PRIVMSG #channel%d :%d", i%3, i))
+	}
+
+	disp := NewDispatcher()
+	var lock sync.Mutex
+	received := make(map[string][]string)
+	disp.OnPrivmsg(func(chat *ChatMessage) {
+		lock.Lock()
+		received[chat.Channel] = append(received[chat.Channel], chat.Text)
+		lock.Unlock()
+	})
+	disp.Run(twitchMessages(raws...))
+
+	for channel := 0; channel < 3; channel++ {
+		texts := received[fmt.Sprintf("channel%d", channel)]
+		for i, text := range texts {
+			if expected := fmt.Sprint(i*3 + channel); text != expected {
+				t.Fatalf("Unexpected order in channel%d :: expected: %s, actual: %s", channel, expected, text)
+			}
+		}
+	}
+}
+
+func TestDispatcher_recoversPanics(t *testing.T) {
+	disp := NewDispatcher()
+	disp.OnPrivmsg(func(chat *ChatMessage) {
+		panic("broken handler")
+	})
+	count := 0
+	disp.OnPrivmsg(func(chat *ChatMessage) {
+		count++
+	})
+
+	disp.Run(twitchMessages(
+		":user!user@user.tmi.twitch.tv PRIVMSG #channel :one",
+		":user!user@user.tmi.twitch.tv PRIVMSG #channel :two",
+	))
+	if count != 2 {
+		t.Errorf("Handlers after a panicking one should still be called: %d", count)
+	}
+}
+
+func TestDispatcher_remove(t *testing.T) {
+	disp := NewDispatcher()
+	count := 0
+	var remove func()
+	remove = disp.OnEvent(func(event Event) {
+		count++
+		remove()
+	})
+
+	disp.Run(twitchMessages("PING :tmi.twitch.tv", "PING :tmi.twitch.tv"))
+	if count != 1 {
+		t.Errorf("Removed handler shouldn't be called again: %d", count)
+	}
+}
+
+func TestDispatcher_malformedTags(t *testing.T) {
+	disp := NewDispatcher()
+	var events []Event
+	disp.OnCommand("PRIVMSG", func(event Event) {
+		events = append(events, event)
+	})
+	chats := 0
+	disp.OnPrivmsg(func(chat *ChatMessage) {
+		chats++
+	})
+
+	disp.Run(twitchMessages("@bits=many :user!user@user.tmi.twitch.tv PRIVMSG #channel :cheer"))
+	if len(events) != 1 {
+		t.Fatalf("Message with malformed tag wasn't dispatched: %v", events)
+	}
+	if _, ok := events[0].(*IRCMessage); !ok || events[0].IRC().Trailing != "cheer" {
+		t.Errorf("Expected parsed message as event but got %#v", events[0])
+	}
+	if chats != 0 {
+		t.Errorf("Typed handler shouldn't receive the malformed message")
+	}
+}
+
+func TestDispatcher_partedChannels(t *testing.T) {
+	disp := NewDispatcher()
+	var received []string
+	disp.OnEvent(func(event Event) {
+		if event.IRC().Channel() == "channel" {
+			received = append(received, event.IRC().Command)
+		}
+	})
+	disp.Run(twitchMessages(
+		":tmi.twitch.tv 001 bot :Welcome, GLHF!",
+		":bot!bot@bot.tmi.twitch.tv JOIN #channel",
+		":user!user@user.tmi.twitch.tv PART #channel",
+		":user!user@user.tmi.twitch.tv PRIVMSG #channel :hi",
+		":bot!bot@bot.tmi.twitch.tv PART #channel",
+		":bot!bot@bot.tmi.twitch.tv JOIN #channel",
+		":user!user@user.tmi.twitch.tv PRIVMSG #channel :hi again",
+	))
+	if actual := fmt.Sprint(received); actual != "[JOIN PART PRIVMSG PART JOIN PRIVMSG]" {
+		t.Errorf("Unexpected events: %s", actual)
+	}
+
+	queues := newDispatchQueues(disp)
+	event, _ := ParseIRC(":bot!bot@bot.tmi.twitch.tv PART #other")
+	queues.dispatch("other", event)
+	queues.remove("other")
+	if len(queues.queues) != 0 {
+		t.Errorf("Queue of parted channel wasn't removed: %v", queues.queues)
+	}
+	queues.close()
+	queues.dispatch("", event)
+	queues.close()
+	if len(queues.queues) != 0 || len(queues.removed) > 1 {
+		t.Errorf("Queues left after closing: %v, %v", queues.queues, queues.removed)
+	}
+}
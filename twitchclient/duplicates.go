@@ -0,0 +1,98 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Twitch rejects a chat message identical to the previous one sent to the channel within this duration.
+const duplicateWindow = 30 * time.Second
+
+// Appended to a message to make it differ from the previous one. The tag character is invisible
+// in chat and the space prevents it from joining the last word.
+const duplicateSuffix = " \U000E0000"
+
+// duplicateBypass varies chat messages identical to the previous message sent to the same channel
+// by alternately appending and removing an invisible suffix, so twitch doesn't reject them.
+type duplicateBypass struct {
+	// Window in which identical messages are rejected by twitch.
+	Window time.Duration
+	// Varied is called with the original and the varied message if a message is varied.
+	Varied func(original, varied *gbc.PlatformMessage)
+}
+
+type sentMessage struct {
+	text string
+	at   time.Time
+}
+
+// Apply the duplicateBypass as a pipeline step to the given channel.
+func (bypass *duplicateBypass) Apply(in <-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
+	out := make(chan *gbc.PlatformMessage)
+	go func() {
+		defer close(out)
+		last := make(map[string]sentMessage)
+		for mssg := range in {
+			varied := bypass.vary(mssg, last, time.Now())
+			if varied != mssg && bypass.Varied != nil {
+				bypass.Varied(mssg, varied)
+			}
+			out <- varied
+		}
+	}()
+	return out
+}
+
+// vary returns the message to send instead of the given one and records it as the last message
+// sent to its channel.
+func (bypass *duplicateBypass) vary(mssg *gbc.PlatformMessage, last map[string]sentMessage, now time.Time) *gbc.PlatformMessage {
+	if mssg.Platform != gbc.Twitch || Message(*mssg).IsWhisper() {
+		return mssg
+	}
+	if command, _ := peekCommand(mssg.RawMessage); command != "PRIVMSG" {
+		return mssg
+	}
+	parsed, err := ParseIRC(mssg.RawMessage)
+	if err != nil || !parsed.HasTrailing {
+		return mssg
+	}
+
+	channel := parsed.Channel()
+	text := parsed.Trailing
+	previous, ok := last[channel]
+	if ok && previous.text == text && now.Sub(previous.at) < bypass.Window {
+		var varied string
+		if strings.HasSuffix(text, duplicateSuffix) {
+			varied = strings.TrimSuffix(text, duplicateSuffix)
+		} else {
+			varied = text + duplicateSuffix
+		}
+		if utf8.RuneCountInString(varied) > maxMessageLength {
+			log.Printf("Can't vary duplicate message without exceeding the length limit: %q", mssg.RawMessage)
+		} else if built, err := rebuild(parsed, varied); err != nil {
+			log.Printf("failed to vary duplicate message %q: %v", mssg.RawMessage, err)
+		} else {
+			text, mssg = varied, built
+		}
+	}
+	last[channel] = sentMessage{text: text, at: now}
+	return mssg
+}
+
+// rebuild creates a message with the tags, command and params of the parsed one but a different
+// trailing parameter.
+func rebuild(parsed *IRCMessage, trailing string) (*gbc.PlatformMessage, error) {
+	var tags map[string]string
+	if parsed.RawTags != "" {
+		tags = parsed.Tags()
+	}
+	return Outbound{
+		Tags:     tags,
+		Command:  parsed.Command,
+		Params:   parsed.Params,
+		Trailing: trailing,
+	}.PlatformMessage()
+}
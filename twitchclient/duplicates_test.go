@@ -0,0 +1,78 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuplicateBypass_vary(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	bypass := duplicateBypass{Window: duplicateWindow}
+	last := make(map[string]sentMessage)
+
+	steps := []struct {
+		offset   time.Duration
+		raw      string
+		expected string
+	}{
+		{0, "PRIVMSG #bot :hello", "PRIVMSG #bot :hello"},
+		{time.Second, "PRIVMSG #bot :hello", "PRIVMSG #bot :hello" + duplicateSuffix},
+		{2 * time.Second, "PRIVMSG #bot :hello", "PRIVMSG #bot :hello"},
+		// Other channels are tracked independently
+		{3 * time.Second, "PRIVMSG #other :hello", "PRIVMSG #other :hello"},
+		// Tags are kept
+		{4 * time.Second, "@reply-parent-msg-id=a PRIVMSG #bot :hello", "@reply-parent-msg-id=a PRIVMSG #bot :hello" + duplicateSuffix},
+		// After the window identical messages are accepted by twitch
+		{40 * time.Second, "PRIVMSG #bot :hello" + duplicateSuffix, "PRIVMSG #bot :hello" + duplicateSuffix},
+		{41 * time.Second, "PRIVMSG #bot :different", "PRIVMSG #bot :different"},
+		{42 * time.Second, "PRIVMSG #bot :hello", "PRIVMSG #bot :hello"},
+		// Whispers and other commands are never varied
+		{43 * time.Second, "PRIVMSG #jtv :/w someone hi", "PRIVMSG #jtv :/w someone hi"},
+		{44 * time.Second, "PRIVMSG #jtv :/w someone hi", "PRIVMSG #jtv :/w someone hi"},
+		{45 * time.Second, "JOIN #bot", "JOIN #bot"},
+	}
+	for _, step := range steps {
+		mssg := &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: step.raw}
+		actual := bypass.vary(mssg, last, start.Add(step.offset))
+		if actual.RawMessage != step.expected {
+			t.Errorf("Vary %q after %v :: expected: %q, actual: %q", step.raw, step.offset, step.expected, actual.RawMessage)
+		}
+	}
+}
+
+func TestDuplicateBypass_lengthLimit(t *testing.T) {
+	bypass := duplicateBypass{Window: duplicateWindow}
+	last := make(map[string]sentMessage)
+	raw := "PRIVMSG #bot :" + strings.Repeat("a", maxMessageLength)
+	now := time.Now()
+
+	bypass.vary(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: raw}, last, now)
+	actual := bypass.vary(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: raw}, last, now)
+	if actual.RawMessage != raw {
+		t.Errorf("Message at the length limit shouldn't be varied: %q", actual.RawMessage)
+	}
+}
+
+func TestDuplicateBypass_Apply(t *testing.T) {
+	in := make(chan *gbc.PlatformMessage)
+	bypass := duplicateBypass{Window: duplicateWindow}
+	out := bypass.Apply(in)
+
+	go func() {
+		defer close(in)
+		in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :hi"}
+		in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :hi"}
+	}()
+
+	expected := []string{"PRIVMSG #bot :hi", "PRIVMSG #bot :hi" + duplicateSuffix}
+	for _, exp := range expected {
+		if actual := receive(t, out); actual != exp {
+			t.Errorf("Unexpected message :: expected: %q, actual: %q", exp, actual)
+		}
+	}
+	if _, ok := <-out; ok {
+		t.Error("Output should be closed after the input")
+	}
+}
@@ -0,0 +1,96 @@
+package twitchclient
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Emote placed in the text of a message.
+type Emote struct {
+	// ID of the emote.
+	ID string
+	// Start is the position of the first character of the emote in Unicode code points.
+	Start int
+	// End is the position of the last character of the emote in Unicode code points.
+	End int
+}
+
+// Fragment is a part of a message text which is either plain text or an emote.
+type Fragment struct {
+	// Text of the fragment. For emotes this is the emote name.
+	Text string
+	// Emote is set if the fragment is an emote. Nil for plain text.
+	Emote *Emote
+}
+
+// ParseEmotes parses the value of the `emotes` tag (`id:start-end,start-end/id:start-end`).
+// Returns the emotes sorted by their position in the text.
+func ParseEmotes(tag string) ([]Emote, error) {
+	var emotes []Emote
+	if tag == "" {
+		return emotes, nil
+	}
+	for _, entry := range strings.Split(tag, "/") {
+		colon := strings.IndexByte(entry, ':')
+		if colon <= 0 {
+			return nil, fmt.Errorf("invalid emote %q: missing id", entry)
+		}
+		id := entry[:colon]
+		for _, position := range strings.Split(entry[colon+1:], ",") {
+			dash := strings.IndexByte(position, '-')
+			if dash == -1 {
+				return nil, fmt.Errorf("invalid position %q of emote %s", position, id)
+			}
+			start, err := strconv.Atoi(position[:dash])
+			if err != nil {
+				return nil, fmt.Errorf("invalid position %q of emote %s: %w", position, id, err)
+			}
+			end, err := strconv.Atoi(position[dash+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid position %q of emote %s: %w", position, id, err)
+			}
+			if start < 0 || end < start {
+				return nil, fmt.Errorf("invalid position %q of emote %s", position, id)
+			}
+			emotes = append(emotes, Emote{ID: id, Start: start, End: end})
+		}
+	}
+	sort.Slice(emotes, func(i, j int) bool {
+		return emotes[i].Start < emotes[j].Start
+	})
+	return emotes, nil
+}
+
+// Fragments splits the text into plain text and emote fragments. As twitch counts the positions
+// of emotes in code points, multi-byte characters like emoji are handled correctly.
+// Emotes overlapping others or exceeding the text are treated as plain text.
+func Fragments(text string, emotes []Emote) []Fragment {
+	runes := []rune(text)
+	var fragments []Fragment
+	position := 0
+	for i := range emotes {
+		emote := emotes[i]
+		if emote.Start < position || emote.End >= len(runes) {
+			continue
+		}
+		if emote.Start > position {
+			fragments = append(fragments, Fragment{Text: string(runes[position:emote.Start])})
+		}
+		fragments = append(fragments, Fragment{
+			Text:  string(runes[emote.Start : emote.End+1]),
+			Emote: &emote,
+		})
+		position = emote.End + 1
+	}
+	if position < len(runes) {
+		fragments = append(fragments, Fragment{Text: string(runes[position:])})
+	}
+	return fragments
+}
+
+// Fragments splits the text of the message into plain text and emote fragments.
+func (chat *ChatMessage) Fragments() []Fragment {
+	return Fragments(chat.Text, chat.Emotes)
+}
@@ -0,0 +1,64 @@
+package twitchclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEmotes(t *testing.T) {
+	emotes, err := ParseEmotes("25:0-4,12-16/1902:6-10")
+	if err != nil {
+		t.Fatalf("Failed to parse emotes: %v", err)
+	}
+	expected := []Emote{
+		{ID: "25", Start: 0, End: 4},
+		{ID: "1902", Start: 6, End: 10},
+		{ID: "25", Start: 12, End: 16},
+	}
+	if !reflect.DeepEqual(emotes, expected) {
+		t.Errorf("Unexpected emotes :: expected: %v, actual: %v", expected, emotes)
+	}
+
+	if emotes, err := ParseEmotes(""); err != nil || len(emotes) != 0 {
+		t.Errorf("Empty tag should return no emotes: %v, %v", emotes, err)
+	}
+
+	for _, invalid := range []string{"25", ":0-4", "25:0", "25:a-4", "25:4-0", "25:0-4,"} {
+		if _, err := ParseEmotes(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestFragments_multiByte(t *testing.T) {
+	// Positions are counted in code points, so the emoji and umlauts before the emote must
+	// only count as one character each.
+	text := "😀 Grüße Kappa 🎉 Kappa"
+	emotes, _ := ParseEmotes("25:8-12,16-20")
+	fragments := Fragments(text, emotes)
+
+	expected := []Fragment{
+		{Text: "😀 Grüße "},
+		{Text: "Kappa", Emote: &Emote{ID: "25", Start: 8, End: 12}},
+		{Text: " 🎉 "},
+		{Text: "Kappa", Emote: &Emote{ID: "25", Start: 16, End: 20}},
+	}
+	if !reflect.DeepEqual(fragments, expected) {
+		t.Errorf("Unexpected fragments :: expected: %+v, actual: %+v", expected, fragments)
+	}
+}
+
+func TestFragments_invalidPositions(t *testing.T) {
+	fragments := Fragments("Kappa", []Emote{{ID: "25", Start: 0, End: 4}, {ID: "1", Start: 2, End: 3}, {ID: "2", Start: 3, End: 10}})
+	if len(fragments) != 1 || fragments[0].Emote == nil || fragments[0].Emote.ID != "25" {
+		t.Errorf("Overlapping and exceeding emotes should be ignored: %+v", fragments)
+	}
+}
+
+func TestChatMessage_Fragments(t *testing.T) {
+	chat := parseEvent(t, "@emotes=25:5-9 :user!user@user.tmi.twitch.tv PRIVMSG #channel :\x01ACTION hey! Kappa\x01").(*ChatMessage)
+	fragments := chat.Fragments()
+	if len(fragments) != 2 || fragments[0].Text != "hey! " || fragments[1].Text != "Kappa" || fragments[1].Emote == nil {
+		t.Errorf("Unexpected fragments: %+v", fragments)
+	}
+}
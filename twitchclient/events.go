@@ -0,0 +1,248 @@
+package twitchclient
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a typed representation of a message received from twitch.
+type Event interface {
+	// IRC returns the parsed message the event was created from.
+	IRC() *IRCMessage
+}
+
+// IRC returns the message itself. This way every parsed message is an Event, which is
+// used for messages without a typed representation.
+func (mssg *IRCMessage) IRC() *IRCMessage {
+	return mssg
+}
+
+// Event parses the message and converts it into a typed event.
+func (mess Message) Event() (Event, error) {
+	parsed, err := mess.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Event()
+}
+
+// Event converts the message into a typed event depending on its command. Messages without
+// a typed representation are returned as they are.
+//
+// The constructors of typed events return typed pointers, so their errors are checked before
+// returning them as Event. Otherwise a failed conversion would return a non-nil Event holding a
+// nil pointer.
+func (mssg *IRCMessage) Event() (Event, error) {
+	switch mssg.Command {
+	case "PRIVMSG":
+		chat, err := NewChatMessage(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return chat, nil
+	case "USERNOTICE":
+		return NewUserNotice(mssg)
+	case "CLEARCHAT":
+		return NewClearChat(mssg)
+	case "CLEARMSG":
+		deleted, err := NewClearMsg(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return deleted, nil
+	case "ROOMSTATE":
+		update, err := NewRoomStateUpdate(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return update, nil
+	case "USERSTATE":
+		state, err := NewUserState(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return state, nil
+	case "GLOBALUSERSTATE":
+		state, err := NewGlobalUserState(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return state, nil
+	case "NOTICE":
+		notice, err := NewNotice(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return notice, nil
+	case "WHISPER":
+		whisper, err := NewWhisper(mssg)
+		if err != nil {
+			return nil, err
+		}
+		return whisper, nil
+	default:
+		return mssg, nil
+	}
+}
+
+// ReplyParent contains information about the message a chat message replies to.
+type ReplyParent struct {
+	// MsgID is the ID of the message replied to.
+	MsgID string
+	// UserID of the author of the message replied to.
+	UserID string
+	// UserLogin of the author of the message replied to.
+	UserLogin string
+	// DisplayName of the author of the message replied to.
+	DisplayName string
+	// Body of the message replied to.
+	Body string
+	// ThreadMsgID is the ID of the first message of the thread.
+	ThreadMsgID string
+	// ThreadUserLogin is the login name of the author of the first message of the thread.
+	ThreadUserLogin string
+}
+
+// ChatMessage is a message sent to a channel (PRIVMSG).
+type ChatMessage struct {
+	irc *IRCMessage
+
+	// Channel the message was sent to without the leading '#'.
+	Channel string
+	// Login name of the sender.
+	Login string
+	// DisplayName of the sender. Falls back to the login name if the tag is missing.
+	DisplayName string
+	// UserID of the sender.
+	UserID string
+	// Badges of the sender.
+	Badges []Badge
+	// Permission level of the sender in the channel.
+	Permission PermissionLevel
+	// Text of the message. For actions the `/me` wrapping is removed.
+	Text string
+	// ID of the message.
+	ID string
+	// Emotes contained in the text sorted by their position. Empty if the emotes tag is malformed.
+	Emotes []Emote
+	// Bits cheered with the message.
+	Bits int
+	// Cheers contains the tokens of global cheermotes cheering the bits. Use `ParseCheers` for
+	// channels with custom cheermotes.
+	Cheers Cheers
+	// Action is set if the message was sent with `/me`.
+	Action bool
+	// Reply contains the parent if the message is a reply. Nil otherwise.
+	Reply *ReplyParent
+	// SentAt is the time the message was sent at. Zero if tags are not enabled.
+	SentAt time.Time
+	// Tags of the message.
+	Tags Tags
+}
+
+// IRC returns the parsed message the chat message was created from.
+func (chat *ChatMessage) IRC() *IRCMessage {
+	return chat.irc
+}
+
+// NewChatMessage creates a ChatMessage from a parsed PRIVMSG.
+func NewChatMessage(mssg *IRCMessage) (*ChatMessage, error) {
+	if mssg.Command != "PRIVMSG" {
+		return nil, fmt.Errorf("expected PRIVMSG but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	chat := &ChatMessage{
+		irc:         mssg,
+		Channel:     mssg.Channel(),
+		Login:       mssg.Prefix.Nick,
+		DisplayName: tags.DisplayName(),
+		UserID:      tags.UserID(),
+		Badges:      tags.Badges(),
+		Permission:  tags.Permission(),
+		Text:        mssg.Param(1),
+		ID:          tags.ID(),
+		SentAt:      tags.SentAt(),
+		Tags:        tags,
+	}
+	if chat.DisplayName == "" {
+		chat.DisplayName = chat.Login
+	}
+	if bits, ok := tags["bits"]; ok {
+		amount, err := strconv.Atoi(bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bits tag %q: %w", bits, err)
+		}
+		chat.Bits = amount
+	}
+	// The text is still valid if twitch sends malformed emotes, so the message is kept without them
+	if emotes, err := ParseEmotes(tags["emotes"]); err != nil {
+		log.Printf("failed to parse emotes of message %s: %v", chat.ID, err)
+	} else {
+		chat.Emotes = emotes
+	}
+	if strings.HasPrefix(chat.Text, "\x01ACTION ") && strings.HasSuffix(chat.Text, "\x01") {
+		chat.Action = true
+		chat.Text = chat.Text[len("\x01ACTION ") : len(chat.Text)-1]
+	}
+	chat.Cheers = chat.ParseCheers()
+	if parentID, ok := tags["reply-parent-msg-id"]; ok {
+		chat.Reply = &ReplyParent{
+			MsgID:       parentID,
+			UserID:      tags["reply-parent-user-id"],
+			UserLogin:   tags["reply-parent-user-login"],
+			DisplayName: tags["reply-parent-display-name"],
+			Body:        tags["reply-parent-msg-body"],
+
+			ThreadMsgID:     tags["reply-thread-parent-msg-id"],
+			ThreadUserLogin: tags["reply-thread-parent-user-login"],
+		}
+	}
+	return chat, nil
+}
+
+// Whisper is a private message sent to the logged in user (WHISPER).
+type Whisper struct {
+	irc *IRCMessage
+
+	// Login name of the sender.
+	Login string
+	// DisplayName of the sender. Falls back to the login name if the tag is missing.
+	DisplayName string
+	// UserID of the sender.
+	UserID string
+	// Text of the whisper.
+	Text string
+	// ID of the whisper.
+	ID string
+	// ThreadID identifies the conversation between the sender and the receiver.
+	ThreadID string
+}
+
+// IRC returns the parsed message the whisper was created from.
+func (whisper *Whisper) IRC() *IRCMessage {
+	return whisper.irc
+}
+
+// NewWhisper creates a Whisper from a parsed WHISPER.
+func NewWhisper(mssg *IRCMessage) (*Whisper, error) {
+	if mssg.Command != "WHISPER" {
+		return nil, fmt.Errorf("expected WHISPER but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	whisper := &Whisper{
+		irc:         mssg,
+		Login:       mssg.Prefix.Nick,
+		DisplayName: tags.DisplayName(),
+		UserID:      tags.UserID(),
+		Text:        mssg.Param(1),
+		ID:          tags["message-id"],
+		ThreadID:    tags["thread-id"],
+	}
+	if whisper.DisplayName == "" {
+		whisper.DisplayName = whisper.Login
+	}
+	return whisper, nil
+}
@@ -0,0 +1,118 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"testing"
+)
+
+func parseEvent(t *testing.T, raw string) Event {
+	t.Helper()
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	event, err := mssg.Event()
+	if err != nil {
+		t.Fatalf("Failed to convert message: %v", err)
+	}
+	return event
+}
+
+func TestMessage_EventChatMessage(t *testing.T) {
+	mess := Message{
+		Platform:   gbc.Twitch,
+		RawMessage: `@bits=100;display-name=Cheerer;id=abc;reply-parent-display-name=Other;reply-parent-msg-body=hello\sthere;reply-parent-msg-id=def;reply-parent-user-id=2;reply-parent-user-login=other;user-id=1 :cheerer!cheerer@cheerer.tmi.twitch.tv PRIVMSG #channel :cheer100 nice`,
+	}
+	event, err := mess.Event()
+	if err != nil {
+		t.Fatalf("Failed to convert message: %v", err)
+	}
+	chat, ok := event.(*ChatMessage)
+	if !ok {
+		t.Fatalf("Expected *ChatMessage but got %T", event)
+	}
+
+	if chat.Channel != "channel" || chat.Login != "cheerer" || chat.DisplayName != "Cheerer" || chat.UserID != "1" {
+		t.Errorf("Unexpected sender information: %+v", chat)
+	}
+	if chat.Text != "cheer100 nice" || chat.ID != "abc" || chat.Bits != 100 || chat.Action {
+		t.Errorf("Unexpected message content: %+v", chat)
+	}
+	expReply := ReplyParent{MsgID: "def", UserID: "2", UserLogin: "other", DisplayName: "Other", Body: "hello there"}
+	if chat.Reply == nil || *chat.Reply != expReply {
+		t.Errorf("Unexpected reply parent: %+v", chat.Reply)
+	}
+	if chat.IRC().Command != "PRIVMSG" {
+		t.Errorf("Should keep parsed message: %+v", chat.IRC())
+	}
+}
+
+func TestNewChatMessage_action(t *testing.T) {
+	mssg, err := ParseIRC(":user!user@user.tmi.twitch.tv PRIVMSG #channel :\x01ACTION waves\x01")
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	chat, err := NewChatMessage(mssg)
+	if err != nil {
+		t.Fatalf("Failed to convert message: %v", err)
+	}
+	if !chat.Action || chat.Text != "waves" {
+		t.Errorf("Expected action with text 'waves': %+v", chat)
+	}
+	if chat.DisplayName != "user" || chat.Reply != nil {
+		t.Errorf("Unexpected defaults without tags: %+v", chat)
+	}
+}
+
+func TestNewChatMessage_invalid(t *testing.T) {
+	mssg, _ := ParseIRC("JOIN #channel")
+	if _, err := NewChatMessage(mssg); err == nil {
+		t.Error("Should fail for non PRIVMSG messages")
+	}
+	mssg, _ = ParseIRC("@bits=lots :user!user@user.tmi.twitch.tv PRIVMSG #channel :cheer")
+	if _, err := NewChatMessage(mssg); err == nil {
+		t.Error("Should fail for invalid bits")
+	}
+}
+
+func TestNewChatMessage_invalidEmotes(t *testing.T) {
+	mssg, _ := ParseIRC("@emotes=x :user!user@user.tmi.twitch.tv PRIVMSG #channel :Kappa")
+	chat, err := NewChatMessage(mssg)
+	if err != nil {
+		t.Fatalf("Malformed emotes shouldn't discard the message: %v", err)
+	}
+	if chat.Text != "Kappa" || len(chat.Emotes) != 0 {
+		t.Errorf("Unexpected message: %+v", chat)
+	}
+}
+
+func TestIRCMessage_EventInvalid(t *testing.T) {
+	invalid := []string{
+		"@bits=lots :user!user@user.tmi.twitch.tv PRIVMSG #channel :cheer",
+		"@slow=slow :tmi.twitch.tv ROOMSTATE #channel",
+	}
+	for _, raw := range invalid {
+		mssg, err := ParseIRC(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", raw, err)
+		}
+		event, err := mssg.Event()
+		if err == nil {
+			t.Errorf("Expected error converting %q", raw)
+		}
+		if event != nil {
+			t.Errorf("Expected nil event converting %q but got %#v", raw, event)
+		}
+	}
+}
+
+func TestIRCMessage_EventFallback(t *testing.T) {
+	mssg, _ := ParseIRC("PING :tmi.twitch.tv")
+	event, err := mssg.Event()
+	if err != nil {
+		t.Fatalf("Failed to convert message: %v", err)
+	}
+	if event != Event(mssg) {
+		t.Errorf("Unknown messages should be returned as they are: %T", event)
+	}
+}
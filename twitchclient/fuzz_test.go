@@ -0,0 +1,94 @@
+//go:build go1.18
+// +build go1.18
+
+package twitchclient
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzParseIRC(f *testing.F) {
+	f.Add(benchmarkMessage)
+	f.Add("PING :tmi.twitch.tv")
+	f.Add(":tmi.twitch.tv 353 bot = #channel :one two")
+	f.Add("@ban-duration=350 :tmi.twitch.tv CLEARCHAT #dallas :ronni")
+	f.Add("@emotes=25:0-4;bits=100 :user!user@user.tmi.twitch.tv PRIVMSG #channel :\x01ACTION Kappa\x01")
+	f.Fuzz(func(t *testing.T, raw string) {
+		mssg, err := ParseIRC(raw)
+		if err != nil {
+			return
+		}
+		if mssg.Command == "" || strings.ContainsAny(mssg.Command, " ") {
+			t.Fatalf("Invalid command %q parsed from %q", mssg.Command, raw)
+		}
+		for _, param := range mssg.Params {
+			if param == "" || strings.Contains(param, " ") || strings.HasPrefix(param, ":") {
+				t.Fatalf("Invalid param %q parsed from %q", param, raw)
+			}
+		}
+		if command, _ := peekCommand(raw); command != mssg.Command {
+			t.Fatalf("Peeked command %q differs from parsed %q for %q", command, mssg.Command, raw)
+		}
+		for key := range mssg.Tags() {
+			if _, ok := mssg.Tag(key); !ok {
+				t.Fatalf("Tag %q contained in map but not found by Tag for %q", key, raw)
+			}
+		}
+		// Converting to events must never panic
+		_, _ = mssg.Event()
+	})
+}
+
+func FuzzEscapeTagValue(f *testing.F) {
+	f.Add(`hello\sworld`)
+	f.Add("semi;colon with space\r\n")
+	f.Fuzz(func(t *testing.T, value string) {
+		if unescaped := unescapeTagValue(escapeTagValue(value)); unescaped != value {
+			t.Fatalf("Escaping %q doesn't revert :: actual: %q", value, unescaped)
+		}
+	})
+}
+
+func FuzzFragments(f *testing.F) {
+	f.Add("😀 Grüße Kappa 🎉 Kappa", "25:8-12,16-20")
+	f.Add("Kappa", "25:0-4/1:2-3")
+	f.Fuzz(func(t *testing.T, text, tag string) {
+		emotes, err := ParseEmotes(tag)
+		if err != nil {
+			return
+		}
+		var joined strings.Builder
+		for _, fragment := range Fragments(text, emotes) {
+			joined.WriteString(fragment.Text)
+		}
+		if joined.String() != string([]rune(text)) {
+			t.Fatalf("Fragments don't add up to the text :: expected: %q, actual: %q", text, joined.String())
+		}
+	})
+}
+
+func FuzzSplitText(f *testing.F) {
+	f.Add("one two three four", 5)
+	f.Add("ab👩‍👩‍👧 🇩🇪🇫🇷 é́́́", 3)
+	f.Fuzz(func(t *testing.T, text string, max int) {
+		if max < 1 || max > 1000 || !utf8.ValidString(text) {
+			return
+		}
+		var joined strings.Builder
+		for _, part := range splitText(text, max, "") {
+			if count := utf8.RuneCountInString(part); count > max {
+				t.Fatalf("Part %q exceeds %d characters", part, max)
+			}
+			if !utf8.ValidString(part) {
+				t.Fatalf("Part %q contains invalid UTF-8", part)
+			}
+			joined.WriteString(part)
+		}
+		// Only spaces at the split points are dropped
+		if strings.Replace(joined.String(), " ", "", -1) != strings.Replace(text, " ", "", -1) {
+			t.Fatalf("Parts don't add up to the text %q", text)
+		}
+	})
+}
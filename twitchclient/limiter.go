@@ -16,48 +16,48 @@ import (
 // - chat message per 30 seconds
 type limiter struct {
 	Mode modes.MessageRateMode
+	// Dropped is called with messages discarded because of the daily limits.
+	Dropped func(mssg *gbc.PlatformMessage)
+	// Expired is checked before a message uses up a slot of the limits. Expired messages are discarded.
+	Expired func(mssg *gbc.PlatformMessage) bool
 }
 
-// Apply the limiter as a pipeline step to the given channel.
-func (lim *limiter) Apply(in <-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
-	whispers := make(chan *gbc.PlatformMessage)
-	chats := make(chan *gbc.PlatformMessage)
-
-	// Split the input into whisper and channel messages
-	go func() {
-		defer close(whispers)
-		defer close(chats)
-		for mssg := range in {
-			if Message(*mssg).IsWhisper() {
-				whispers <- mssg
-			} else {
-				chats <- mssg
-			}
-
-		}
-	}()
+// Apply the limiter as a pipeline step to the given lanes. The lanes are ordered by priority,
+// so whenever a limit allows sending the next message, messages of earlier lanes are sent first.
+func (lim *limiter) Apply(lanes ...<-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
+	chatLanes := make([]<-chan *gbc.PlatformMessage, len(lanes))
+	whisperLanes := make([]<-chan *gbc.PlatformMessage, len(lanes))
+	for i, in := range lanes {
+		chatLanes[i], whisperLanes[i] = classify(in)
+	}
 
 	//// Start Limiters
 	// Create channel limiting the chat output
 	limit := &internal.Limiter{
 		Duration: 30 * time.Second,
 		Limit:    lim.Mode.ToChatPer30Seconds(),
+		Expired:  lim.Expired,
 	}
-	chatOut := limit.Apply(chats)
+	chatOut := limit.Apply(internal.Prioritize(chatLanes...))
 	//// Chain Whisper-limits
-	// Limit daily contacted accounts
-	daily := dailyLimiter{Limit: lim.Mode.ToWhisperAccountsPerDay()}
-	whisperAccOut := daily.Apply(whispers)
+	// Limit daily contacted accounts shared by all lanes
+	daily := &dailyLimiter{Limit: lim.Mode.ToWhisperAccountsPerDay(), Dropped: lim.Dropped, Expired: lim.Expired}
+	whisperAccOut := make([]<-chan *gbc.PlatformMessage, len(whisperLanes))
+	for i, whispers := range whisperLanes {
+		whisperAccOut[i] = daily.Apply(whispers)
+	}
 	// Limit Messages whispered per minute
 	minLimiter := &internal.Limiter{
 		Duration: time.Minute,
 		Limit:    lim.Mode.ToWhisperPerMinute(),
+		Expired:  lim.Expired,
 	}
-	whisperMinuteOut := minLimiter.Apply(whisperAccOut)
+	whisperMinuteOut := minLimiter.Apply(internal.Prioritize(whisperAccOut...))
 	// Limit Messages whispered per second
 	secLimiter := &internal.Limiter{
 		Duration: time.Second,
 		Limit:    lim.Mode.ToWhisperPerSecond(),
+		Expired:  lim.Expired,
 	}
 	whisperOut := secLimiter.Apply(whisperMinuteOut)
 
@@ -65,6 +65,24 @@ func (lim *limiter) Apply(in <-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMe
 	return fanIn(chatOut, whisperOut)
 }
 
+// classify splits the input into channel and whisper messages.
+func classify(in <-chan *gbc.PlatformMessage) (chats, whispers <-chan *gbc.PlatformMessage) {
+	chatOut := make(chan *gbc.PlatformMessage)
+	whisperOut := make(chan *gbc.PlatformMessage)
+	go func() {
+		defer close(whisperOut)
+		defer close(chatOut)
+		for mssg := range in {
+			if Message(*mssg).IsWhisper() {
+				whisperOut <- mssg
+			} else {
+				chatOut <- mssg
+			}
+		}
+	}()
+	return chatOut, whisperOut
+}
+
 func fanIn(in ...<-chan *gbc.PlatformMessage) <-chan *gbc.PlatformMessage {
 	var wg sync.WaitGroup
 	out := make(chan *gbc.PlatformMessage)
@@ -185,3 +185,56 @@ func TestTwitchLimiter_ChatPer30Seconds(t *testing.T) {
 		}
 	}
 }
+
+// Tests messages of higher priority overtake queued messages of lower priority
+func TestTwitchLimiter_Priority(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Waits for the chat limit of the KNOWN mode")
+	}
+	critical := make(chan *gbc.PlatformMessage, 5)
+	bulk := make(chan *gbc.PlatformMessage, 5)
+	defer close(critical)
+	defer close(bulk)
+	for i := 1; i <= 3; i++ {
+		bulk <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: fmt.Sprintf("PRIVMSG #test :bulk %d", i)}
+	}
+
+	lim := &limiter{Mode: modes.KNOWN}
+	out := lim.Apply(critical, bulk)
+
+	next := func() string {
+		select {
+		case mssg := <-out:
+			return mssg.RawMessage
+		case <-time.NewTimer(2 * time.Second).C:
+			t.Fatal("Timed out waiting for message")
+			return ""
+		}
+	}
+
+	if mssg := next(); mssg != "PRIVMSG #test :bulk 1" {
+		t.Fatalf("Unexpected message: %q", mssg)
+	}
+	critical <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #test :/timeout spammer 600"}
+	for _, expected := range []string{"PRIVMSG #test :/timeout spammer 600", "PRIVMSG #test :bulk 2"} {
+		if mssg := next(); mssg != expected {
+			t.Errorf("Unexpected message :: expected: %q, actual: %q", expected, mssg)
+		}
+	}
+}
+
+func TestPriority_order(t *testing.T) {
+	if !(PriorityCritical > PriorityNormal && PriorityNormal > PriorityBulk) {
+		t.Errorf("Priorities aren't ordered by rank: %d, %d, %d", PriorityCritical, PriorityNormal, PriorityBulk)
+	}
+	var zero Priority
+	if zero != PriorityNormal {
+		t.Errorf("Zero value should be %v but is %v", PriorityNormal, zero)
+	}
+	// Lanes are ordered from highest to lowest priority
+	for i := 1; i < len(priorities); i++ {
+		if priorities[i-1] <= priorities[i] {
+			t.Errorf("Lane %d has a lower priority than lane %d", i-1, i)
+		}
+	}
+}
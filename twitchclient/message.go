@@ -0,0 +1,277 @@
+package twitchclient
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Message sent from/to twitch.
+type Message gbc.PlatformMessage
+
+// Parse the raw message into its IRCv3 components.
+func (mess Message) Parse() (*IRCMessage, error) {
+	return ParseIRC(mess.RawMessage)
+}
+
+// IsWhisper returns if the message represents a whisper message. Whispers are either sent as
+// `WHISPER` or as `/w <user> <text>` chat command in a PRIVMSG.
+func (mess Message) IsWhisper() bool {
+	command, params := peekCommand(mess.RawMessage)
+	switch command {
+	case "WHISPER":
+		return true
+	case "PRIVMSG":
+		_, ok := whisperTarget(params)
+		return ok
+	default:
+		return false
+	}
+}
+
+// Receipt extracts the first parameter of a whisper or privmsg as that represents the
+// user/channel the message is extracted to. For whispers sent as chat command the receiving
+// user is returned.
+func (mess Message) Receipt() string {
+	command, params := peekCommand(mess.RawMessage)
+	if command != "WHISPER" && command != "PRIVMSG" {
+		return ""
+	}
+	if command == "PRIVMSG" {
+		if user, ok := whisperTarget(params); ok {
+			return user
+		}
+	}
+	params = strings.TrimLeft(params, " ")
+	if strings.HasPrefix(params, ":") {
+		return params[1:]
+	}
+	if end := strings.IndexByte(params, ' '); end != -1 {
+		return params[:end]
+	}
+	return params
+}
+
+// whisperTarget returns the receiving user if the parameters of a PRIVMSG contain the
+// `/w <user> <text>` chat command.
+func whisperTarget(params string) (string, bool) {
+	start := strings.Index(params, " :")
+	if start == -1 {
+		return "", false
+	}
+	user, _, ok := whisperCommand(params[start+2:])
+	return strings.ToLower(user), ok
+}
+
+// whisperCommand splits the text of a `/w <user> <text>` chat command into the receiving user
+// and the whispered text.
+func whisperCommand(text string) (user, whisper string, ok bool) {
+	if !strings.HasPrefix(text, "/w ") && !strings.HasPrefix(text, ".w ") {
+		return "", "", false
+	}
+	text = strings.TrimLeft(text[3:], " ")
+	end := strings.IndexByte(text, ' ')
+	if end == -1 {
+		end = len(text)
+	}
+	if end == 0 {
+		return "", "", false
+	}
+	return text[:end], strings.TrimLeft(text[end:], " "), true
+}
+
+// peekCommand returns the command and the unparsed parameters of a raw message without
+// parsing it completely. Returns an empty command for invalid messages.
+func peekCommand(raw string) (command, params string) {
+	line := strings.TrimRight(raw, "\r\n")
+	if strings.HasPrefix(line, "@") {
+		end := strings.IndexByte(line, ' ')
+		if end == -1 {
+			return "", ""
+		}
+		line = line[end+1:]
+	}
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, ":") {
+		end := strings.IndexByte(line, ' ')
+		if end == -1 {
+			return "", ""
+		}
+		line = strings.TrimLeft(line[end+1:], " ")
+	}
+	end := strings.IndexByte(line, ' ')
+	if end == -1 {
+		return line, ""
+	}
+	return line[:end], line[end:]
+}
+
+// readFrame reads a whole websocket frame into the buffer, which is reused for all frames of a
+// connection, and returns it as string. All lines of the frame share the memory of the string.
+func readFrame(reader io.Reader, buffer *bytes.Buffer) (string, error) {
+	buffer.Reset()
+	if _, err := buffer.ReadFrom(reader); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// nextLine splits the first line from the data. Lines are separated by "\n" which can
+// be preceded by "\r". Only substrings are returned, so no memory is allocated.
+func nextLine(data string) (line, rest string) {
+	end := strings.IndexByte(data, '\n')
+	if end == -1 {
+		line, rest = data, ""
+	} else {
+		line, rest = data[:end], data[end+1:]
+	}
+	return strings.TrimSuffix(line, "\r"), rest
+}
+
+// Prefix of a message identifying its origin. Messages sent by the server only contain
+// the servername which is stored as Nick.
+type Prefix struct {
+	Nick string
+	User string
+	Host string
+}
+
+// String formats the prefix the way it is represented in a raw message (without leading ':').
+func (prefix Prefix) String() string {
+	result := prefix.Nick
+	if prefix.User != "" {
+		result += "!" + prefix.User
+	}
+	if prefix.Host != "" {
+		result += "@" + prefix.Host
+	}
+	return result
+}
+
+// IRCMessage is a message split into its components as defined by the IRCv3 message format:
+//
+//	[@tags] [:prefix] <command> [middle params] [:trailing]
+//
+// All components reference the raw line, so parsing only allocates the message itself.
+// Tags are parsed lazily on first access.
+type IRCMessage struct {
+	// Raw contains the line the message was parsed from.
+	Raw string
+	// RawTags contains the unparsed tags without the leading '@'.
+	RawTags string
+	// Prefix of the message. Empty if the message has no prefix.
+	Prefix Prefix
+	// Command of the message, e.g. PRIVMSG or a numeric reply like 353.
+	Command string
+	// Params contains the middle parameters.
+	Params []string
+	// Trailing contains the trailing parameter (everything after " :").
+	Trailing string
+	// HasTrailing reports if the message contained a trailing parameter, even if it was empty.
+	HasTrailing bool
+
+	// params is used as backing array of Params as twitch never sends more middle params.
+	params   [3]string
+	tagsOnce sync.Once
+	tags     Tags
+}
+
+// ParseIRC parses a single raw line into an IRCMessage.
+func ParseIRC(raw string) (*IRCMessage, error) {
+	line := strings.TrimRight(raw, "\r\n")
+	if strings.ContainsAny(line, "\r\n\x00") {
+		return nil, fmt.Errorf("invalid message %q: contains line break or NUL", raw)
+	}
+	mssg := &IRCMessage{Raw: line}
+
+	if strings.HasPrefix(line, "@") {
+		end := strings.IndexByte(line, ' ')
+		if end == -1 {
+			return nil, fmt.Errorf("invalid message %q: missing command after tags", raw)
+		}
+		mssg.RawTags = line[1:end]
+		line = line[end+1:]
+	}
+	line = strings.TrimLeft(line, " ")
+
+	if strings.HasPrefix(line, ":") {
+		end := strings.IndexByte(line, ' ')
+		if end == -1 {
+			return nil, fmt.Errorf("invalid message %q: missing command after prefix", raw)
+		}
+		mssg.Prefix = parsePrefix(line[1:end])
+		line = strings.TrimLeft(line[end+1:], " ")
+	}
+
+	end := strings.IndexByte(line, ' ')
+	if end == -1 {
+		end = len(line)
+	}
+	mssg.Command = line[:end]
+	if mssg.Command == "" {
+		return nil, fmt.Errorf("invalid message %q: missing command", raw)
+	}
+	line = line[end:]
+
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		if line[0] == ':' {
+			mssg.Trailing = line[1:]
+			mssg.HasTrailing = true
+			break
+		}
+		end := strings.IndexByte(line, ' ')
+		if end == -1 {
+			end = len(line)
+		}
+		if mssg.Params == nil {
+			mssg.Params = mssg.params[:0]
+		}
+		mssg.Params = append(mssg.Params, line[:end])
+		line = line[end:]
+	}
+
+	return mssg, nil
+}
+
+func parsePrefix(raw string) Prefix {
+	var prefix Prefix
+	if at := strings.IndexByte(raw, '@'); at != -1 {
+		prefix.Host = raw[at+1:]
+		raw = raw[:at]
+	}
+	if excl := strings.IndexByte(raw, '!'); excl != -1 {
+		prefix.User = raw[excl+1:]
+		raw = raw[:excl]
+	}
+	prefix.Nick = raw
+	return prefix
+}
+
+// Param returns the i-th parameter of the message where the trailing parameter is
+// counted as last parameter. Returns an empty string if the parameter doesn't exist.
+func (mssg *IRCMessage) Param(i int) string {
+	if i < len(mssg.Params) {
+		return mssg.Params[i]
+	}
+	if i == len(mssg.Params) && mssg.HasTrailing {
+		return mssg.Trailing
+	}
+	return ""
+}
+
+// Channel returns the channel the message is targeted at without the leading '#'.
+// Returns an empty string if the first parameter isn't a channel.
+func (mssg *IRCMessage) Channel() string {
+	first := mssg.Param(0)
+	if !strings.HasPrefix(first, "#") {
+		return ""
+	}
+	return first[1:]
+}
@@ -0,0 +1,195 @@
+package twitchclient
+
+import (
+	"bytes"
+	"github.com/MoBlaa/gbc"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIRC_full(t *testing.T) {
+	raw := "@badge-info=;color=#0000FF;display-name=Ronni :ronni!ronni@ronni.tmi.twitch.tv PRIVMSG #dallas :Kappa Keepo Kappa\r\n"
+	mssg, err := ParseIRC(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+
+	if mssg.RawTags != "badge-info=;color=#0000FF;display-name=Ronni" {
+		t.Errorf("Unexpected tags: %q", mssg.RawTags)
+	}
+	expPrefix := Prefix{Nick: "ronni", User: "ronni", Host: "ronni.tmi.twitch.tv"}
+	if mssg.Prefix != expPrefix {
+		t.Errorf("Unexpected prefix: %+v", mssg.Prefix)
+	}
+	if mssg.Command != "PRIVMSG" {
+		t.Errorf("Unexpected command: %q", mssg.Command)
+	}
+	if !reflect.DeepEqual(mssg.Params, []string{"#dallas"}) {
+		t.Errorf("Unexpected params: %q", mssg.Params)
+	}
+	if !mssg.HasTrailing || mssg.Trailing != "Kappa Keepo Kappa" {
+		t.Errorf("Unexpected trailing: %q", mssg.Trailing)
+	}
+	if mssg.Channel() != "dallas" {
+		t.Errorf("Unexpected channel: %q", mssg.Channel())
+	}
+}
+
+func TestParseIRC_minimal(t *testing.T) {
+	mssg, err := ParseIRC("PING :tmi.twitch.tv")
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if mssg.Command != "PING" || mssg.Trailing != "tmi.twitch.tv" || len(mssg.Params) != 0 {
+		t.Errorf("Unexpected result: %+v", mssg)
+	}
+
+	mssg, err = ParseIRC(":tmi.twitch.tv 353 bot = #channel :one two")
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if mssg.Prefix.Nick != "tmi.twitch.tv" || mssg.Command != "353" {
+		t.Errorf("Unexpected result: %+v", mssg)
+	}
+	if !reflect.DeepEqual(mssg.Params, []string{"bot", "=", "#channel"}) || mssg.Param(3) != "one two" {
+		t.Errorf("Unexpected params: %q %q", mssg.Params, mssg.Trailing)
+	}
+
+	mssg, err = ParseIRC("JOIN #channel")
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if mssg.HasTrailing || mssg.Param(0) != "#channel" || mssg.Param(1) != "" {
+		t.Errorf("Unexpected result: %+v", mssg)
+	}
+}
+
+func TestParseIRC_invalid(t *testing.T) {
+	for _, raw := range []string{"", "   ", "@tags-only", ":prefix-only", "@a=b :prefix", "PRIVMSG #a :b\r\nPART #a"} {
+		if _, err := ParseIRC(raw); err == nil {
+			t.Errorf("Expected error for %q", raw)
+		}
+	}
+}
+
+func TestMessage_IsWhisper(t *testing.T) {
+	whispers := []string{
+		"WHISPER one :D:",
+		":sender!sender@sender.tmi.twitch.tv WHISPER receiver :hello",
+		"@badges=;color= :sender!sender@sender.tmi.twitch.tv WHISPER receiver :hello",
+		"PRIVMSG #jtv :/w receiver hello",
+		"@client-nonce=abc PRIVMSG #bot :.w receiver hello",
+	}
+	for _, raw := range whispers {
+		if !(Message{Platform: gbc.Twitch, RawMessage: raw}).IsWhisper() {
+			t.Errorf("Should be a whisper: %q", raw)
+		}
+	}
+
+	others := []string{
+		"PRIVMSG #test :WHISPER me something",
+		"@msg-id=WHISPER :user!user@user.tmi.twitch.tv PRIVMSG #test :hi",
+		"PRIVMSG #test :say /w receiver hello",
+		"PRIVMSG #test :/w ",
+		"PRIVMSG #test :/whisper",
+	}
+	for _, raw := range others {
+		if (Message{Platform: gbc.Twitch, RawMessage: raw}).IsWhisper() {
+			t.Errorf("Shouldn't be a whisper: %q", raw)
+		}
+	}
+}
+
+func TestMessage_Receipt(t *testing.T) {
+	cases := map[string]string{
+		"WHISPER one :D:":          "one",
+		"WHISPER one":              "one",
+		"PRIVMSG #test :D:":        "#test",
+		"PRIVMSG #jtv :/w One hi":  "one",
+		"@a=b PRIVMSG #test :D:":   "#test",
+		"JOIN #test":               "",
+		"PING :tmi.twitch.tv":      "",
+		":tmi.twitch.tv RECONNECT": "",
+	}
+	for raw, expected := range cases {
+		actual := Message{Platform: gbc.Twitch, RawMessage: raw}.Receipt()
+		if actual != expected {
+			t.Errorf("Receipt of %q :: expected: %q, actual: %q", raw, expected, actual)
+		}
+	}
+}
+
+func TestNextLine(t *testing.T) {
+	var lines []string
+	frame := "PING :tmi.twitch.tv\r\n:user!user@user.tmi.twitch.tv JOIN #channel\nlast"
+	for frame != "" {
+		var line string
+		line, frame = nextLine(frame)
+		lines = append(lines, line)
+	}
+	expected := []string{"PING :tmi.twitch.tv", ":user!user@user.tmi.twitch.tv JOIN #channel", "last"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Unexpected lines :: expected: %q, actual: %q", expected, lines)
+	}
+}
+
+const benchmarkMessage = "@badge-info=subscriber/8;badges=subscriber/6,premium/1;color=#0000FF;display-name=Ronni;emotes=25:0-4;first-msg=0;id=b34ccfc7-4977-403a-8a94-33c6bac34fb8;mod=0;returning-chatter=0;room-id=1337;subscriber=1;tmi-sent-ts=1507246572675;turbo=0;user-id=4242;user-type= :ronni!ronni@ronni.tmi.twitch.tv PRIVMSG #dallas :Kappa Keepo Kappa"
+
+// Allocations per operation measured with `go test -bench . -benchmem` before and after parsing
+// with substrings of the raw message and reusing the read buffer of the connection:
+//
+//	ParseIRC            2 -> 1
+//	Message.IsWhisper   2 -> 0
+//	Message.Receipt     2 -> 0
+//	IRCMessage.Tags    10 -> 5
+//	IRCMessage.Tag      8 -> 0
+//	readFrame          12 -> 1 (frame of 20 lines, before read with websocket.Conn.ReadMessage)
+func BenchmarkParseIRC(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseIRC(benchmarkMessage); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessage_IsWhisper(b *testing.B) {
+	mess := Message{Platform: gbc.Twitch, RawMessage: benchmarkMessage}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mess.IsWhisper()
+	}
+}
+
+func BenchmarkMessage_Receipt(b *testing.B) {
+	mess := Message{Platform: gbc.Twitch, RawMessage: benchmarkMessage}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mess.Receipt()
+	}
+}
+
+func BenchmarkReadFrame(b *testing.B) {
+	data := []byte(strings.Repeat(benchmarkMessage+"\r\n", 20))
+	var buffer bytes.Buffer
+	reader := bytes.NewReader(data)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(data)
+		if _, err := readFrame(reader, &buffer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNextLine(b *testing.B) {
+	frame := benchmarkMessage + "\r\n" + benchmarkMessage + "\r\nPING :tmi.twitch.tv\r\n"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rest := frame
+		for rest != "" {
+			_, rest = nextLine(rest)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package twitchclient
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ChatCleared is sent when all messages of a channel were removed (CLEARCHAT without a user).
+type ChatCleared struct {
+	irc *IRCMessage
+
+	// Channel which was cleared without the leading '#'.
+	Channel string
+	// SentAt is the time the chat was cleared at. Zero if tags are not enabled.
+	SentAt time.Time
+}
+
+// IRC returns the parsed message the event was created from.
+func (cleared *ChatCleared) IRC() *IRCMessage {
+	return cleared.irc
+}
+
+// UserBanned is sent when a user was permanently banned from a channel.
+type UserBanned struct {
+	irc *IRCMessage
+
+	// Channel the user was banned from without the leading '#'.
+	Channel string
+	// Login name of the banned user.
+	Login string
+	// UserID of the banned user. Empty if tags are not enabled.
+	UserID string
+	// SentAt is the time the user was banned at. Zero if tags are not enabled.
+	SentAt time.Time
+}
+
+// IRC returns the parsed message the event was created from.
+func (banned *UserBanned) IRC() *IRCMessage {
+	return banned.irc
+}
+
+// UserTimedOut is sent when a user was temporarily banned from a channel.
+type UserTimedOut struct {
+	irc *IRCMessage
+
+	// Channel the user was timed out in without the leading '#'.
+	Channel string
+	// Login name of the timed out user.
+	Login string
+	// UserID of the timed out user.
+	UserID string
+	// Duration of the timeout.
+	Duration time.Duration
+	// SentAt is the time the user was timed out at.
+	SentAt time.Time
+}
+
+// IRC returns the parsed message the event was created from.
+func (timeout *UserTimedOut) IRC() *IRCMessage {
+	return timeout.irc
+}
+
+// MessageDeleted is sent when a single message was removed from a channel (CLEARMSG).
+type MessageDeleted struct {
+	irc *IRCMessage
+
+	// Channel the message was deleted from without the leading '#'.
+	Channel string
+	// Login name of the author of the deleted message.
+	Login string
+	// TargetMsgID is the ID of the deleted message.
+	TargetMsgID string
+	// Text of the deleted message.
+	Text string
+	// SentAt is the time the message was deleted at.
+	SentAt time.Time
+}
+
+// IRC returns the parsed message the event was created from.
+func (deleted *MessageDeleted) IRC() *IRCMessage {
+	return deleted.irc
+}
+
+// NewClearChat creates a ChatCleared, UserBanned or UserTimedOut event from a parsed CLEARCHAT.
+// A CLEARCHAT targeting a user is a timeout if it contains the `ban-duration` tag.
+func NewClearChat(mssg *IRCMessage) (Event, error) {
+	if mssg.Command != "CLEARCHAT" {
+		return nil, fmt.Errorf("expected CLEARCHAT but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	login := mssg.Param(1)
+	if login == "" {
+		return &ChatCleared{
+			irc:     mssg,
+			Channel: mssg.Channel(),
+			SentAt:  tags.SentAt(),
+		}, nil
+	}
+
+	duration, ok := tags["ban-duration"]
+	if !ok {
+		return &UserBanned{
+			irc:     mssg,
+			Channel: mssg.Channel(),
+			Login:   login,
+			UserID:  tags["target-user-id"],
+			SentAt:  tags.SentAt(),
+		}, nil
+	}
+	seconds, err := strconv.Atoi(duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ban-duration %q: %w", duration, err)
+	}
+	return &UserTimedOut{
+		irc:      mssg,
+		Channel:  mssg.Channel(),
+		Login:    login,
+		UserID:   tags["target-user-id"],
+		Duration: time.Duration(seconds) * time.Second,
+		SentAt:   tags.SentAt(),
+	}, nil
+}
+
+// NewClearMsg creates a MessageDeleted event from a parsed CLEARMSG.
+func NewClearMsg(mssg *IRCMessage) (*MessageDeleted, error) {
+	if mssg.Command != "CLEARMSG" {
+		return nil, fmt.Errorf("expected CLEARMSG but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	return &MessageDeleted{
+		irc:         mssg,
+		Channel:     mssg.Channel(),
+		Login:       tags["login"],
+		TargetMsgID: tags["target-msg-id"],
+		Text:        mssg.Param(1),
+		SentAt:      tags.SentAt(),
+	}, nil
+}
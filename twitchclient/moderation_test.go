@@ -0,0 +1,45 @@
+package twitchclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewClearChat(t *testing.T) {
+	event := parseEvent(t, "@room-id=12345678;tmi-sent-ts=1642715695392 :tmi.twitch.tv CLEARCHAT #dallas")
+	if cleared, ok := event.(*ChatCleared); !ok || cleared.Channel != "dallas" || cleared.SentAt.IsZero() {
+		t.Errorf("Expected chat cleared event: %#v", event)
+	}
+
+	event = parseEvent(t, "@room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642715756806 :tmi.twitch.tv CLEARCHAT #dallas :ronni")
+	if banned, ok := event.(*UserBanned); !ok || banned.Channel != "dallas" || banned.Login != "ronni" || banned.UserID != "87654321" {
+		t.Errorf("Expected user banned event: %#v", event)
+	}
+
+	event = parseEvent(t, "@ban-duration=350;room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642719320727 :tmi.twitch.tv CLEARCHAT #dallas :ronni")
+	timeout, ok := event.(*UserTimedOut)
+	if !ok {
+		t.Fatalf("Expected *UserTimedOut but got %T", event)
+	}
+	if timeout.Login != "ronni" || timeout.UserID != "87654321" || timeout.Duration != 350*time.Second {
+		t.Errorf("Unexpected timeout: %+v", timeout)
+	}
+}
+
+func TestNewClearChat_invalidDuration(t *testing.T) {
+	mssg, _ := ParseIRC("@ban-duration=forever :tmi.twitch.tv CLEARCHAT #dallas :ronni")
+	if _, err := NewClearChat(mssg); err == nil {
+		t.Error("Should fail for invalid ban-duration")
+	}
+}
+
+func TestNewClearMsg(t *testing.T) {
+	event := parseEvent(t, "@login=foo;room-id=;target-msg-id=94e6c7ff-bf98-4faa-af5d-7ad633a158a9;tmi-sent-ts=1642720582342 :tmi.twitch.tv CLEARMSG #bar :what a great day")
+	deleted, ok := event.(*MessageDeleted)
+	if !ok {
+		t.Fatalf("Expected *MessageDeleted but got %T", event)
+	}
+	if deleted.Channel != "bar" || deleted.Login != "foo" || deleted.TargetMsgID != "94e6c7ff-bf98-4faa-af5d-7ad633a158a9" || deleted.Text != "what a great day" {
+		t.Errorf("Unexpected deleted message: %+v", deleted)
+	}
+}
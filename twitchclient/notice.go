@@ -0,0 +1,135 @@
+package twitchclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors reported by twitch through NOTICE messages. Use `errors.Is` to check the error
+// returned by `Notice.Err()` against them.
+var (
+	// ErrLoginFailed is reported if the token or username used to login are invalid.
+	ErrLoginFailed = errors.New("login authentication failed")
+	// ErrBanned is reported if the user is banned from the channel.
+	ErrBanned = errors.New("banned from channel")
+	// ErrTimedOut is reported if the user is timed out in the channel.
+	ErrTimedOut = errors.New("timed out in channel")
+	// ErrRateLimit is reported if messages are sent too quickly.
+	ErrRateLimit = errors.New("sending messages too quickly")
+	// ErrDuplicate is reported if the message is identical to the previous one sent within 30 seconds.
+	ErrDuplicate = errors.New("duplicate message")
+	// ErrChannelSuspended is reported if the channel was suspended.
+	ErrChannelSuspended = errors.New("channel suspended")
+	// ErrFollowersOnly is reported if the user isn't allowed to chat in followers-only mode.
+	ErrFollowersOnly = errors.New("channel is in followers-only mode")
+	// ErrSlowMode is reported if the user sends messages faster than allowed in slow mode.
+	ErrSlowMode = errors.New("channel is in slow mode")
+	// ErrSubsOnly is reported if the user isn't allowed to chat in subscribers-only mode.
+	ErrSubsOnly = errors.New("channel is in subscribers-only mode")
+	// ErrEmoteOnly is reported if the message contains other content than emotes in emote-only mode.
+	ErrEmoteOnly = errors.New("channel is in emote-only mode")
+	// ErrR9K is reported if the message isn't unique in r9k mode.
+	ErrR9K = errors.New("channel is in unique-chat mode")
+	// ErrVerificationRequired is reported if the account has to verify its email or phone number to chat.
+	ErrVerificationRequired = errors.New("account verification required")
+	// ErrMessageRejected is reported for all other `msg_*` notices rejecting a message.
+	ErrMessageRejected = errors.New("message rejected")
+)
+
+// noticeErrors maps the `msg-id` of NOTICE messages to the reported errors.
+var noticeErrors = map[string]error{
+	"msg_banned":                         ErrBanned,
+	"msg_timedout":                       ErrTimedOut,
+	"msg_ratelimit":                      ErrRateLimit,
+	"msg_duplicate":                      ErrDuplicate,
+	"msg_channel_suspended":              ErrChannelSuspended,
+	"msg_followersonly":                  ErrFollowersOnly,
+	"msg_followersonly_followed":         ErrFollowersOnly,
+	"msg_followersonly_zero":             ErrFollowersOnly,
+	"msg_slowmode":                       ErrSlowMode,
+	"msg_subsonly":                       ErrSubsOnly,
+	"msg_emoteonly":                      ErrEmoteOnly,
+	"msg_r9k":                            ErrR9K,
+	"msg_verified_email":                 ErrVerificationRequired,
+	"msg_requires_verified_phone_number": ErrVerificationRequired,
+}
+
+// NoticeError is a failure reported by twitch through a NOTICE message.
+type NoticeError struct {
+	// Channel the notice was sent to without the leading '#'. Empty for notices not related to a channel.
+	Channel string
+	// MsgID of the notice. Empty for login failures as twitch doesn't send tags for them.
+	MsgID string
+	// Text of the notice.
+	Text string
+	// Err is the error from the catalogue the notice maps to.
+	Err error
+}
+
+func (err *NoticeError) Error() string {
+	if err.MsgID == "" {
+		return fmt.Sprintf("%v: %s", err.Err, err.Text)
+	}
+	return fmt.Sprintf("%v (%s): %s", err.Err, err.MsgID, err.Text)
+}
+
+// Unwrap returns the error from the catalogue to support `errors.Is`.
+func (err *NoticeError) Unwrap() error {
+	return err.Err
+}
+
+// Notice is a NOTICE message sent by twitch.
+type Notice struct {
+	irc *IRCMessage
+
+	// Channel the notice was sent to without the leading '#'. Empty for notices not related to a channel.
+	Channel string
+	// MsgID identifies the notice. Only available if tags are enabled.
+	MsgID string
+	// Text of the notice.
+	Text string
+}
+
+// IRC returns the parsed message the notice was created from.
+func (notice *Notice) IRC() *IRCMessage {
+	return notice.irc
+}
+
+// Err returns a *NoticeError if the notice reports a failure. Returns nil for informational notices.
+func (notice *Notice) Err() error {
+	cause, ok := noticeErrors[notice.MsgID]
+	switch {
+	case ok:
+	case notice.MsgID == "" && isLoginFailure(notice.Text):
+		cause = ErrLoginFailed
+	case strings.HasPrefix(notice.MsgID, "msg_"):
+		cause = ErrMessageRejected
+	default:
+		return nil
+	}
+	return &NoticeError{
+		Channel: notice.Channel,
+		MsgID:   notice.MsgID,
+		Text:    notice.Text,
+		Err:     cause,
+	}
+}
+
+func isLoginFailure(text string) bool {
+	return text == "Login authentication failed" || text == "Improperly formatted auth"
+}
+
+// NewNotice creates a Notice from a parsed NOTICE.
+func NewNotice(mssg *IRCMessage) (*Notice, error) {
+	if mssg.Command != "NOTICE" {
+		return nil, fmt.Errorf("expected NOTICE but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	return &Notice{
+		irc:     mssg,
+		Channel: mssg.Channel(),
+		MsgID:   tags["msg-id"],
+		Text:    mssg.Param(1),
+	}, nil
+}
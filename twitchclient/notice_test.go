@@ -0,0 +1,46 @@
+package twitchclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func noticeErr(t *testing.T, raw string) error {
+	t.Helper()
+	event := parseEvent(t, raw)
+	notice, ok := event.(*Notice)
+	if !ok {
+		t.Fatalf("Expected *Notice but got %T", event)
+	}
+	return notice.Err()
+}
+
+func TestNotice_Err(t *testing.T) {
+	cases := map[string]error{
+		"@msg-id=msg_banned :tmi.twitch.tv NOTICE #channel :You are permanently banned from talking in channel.":               ErrBanned,
+		"@msg-id=msg_ratelimit :tmi.twitch.tv NOTICE #channel :Your message was not sent because you are sending too quickly.": ErrRateLimit,
+		"@msg-id=msg_duplicate :tmi.twitch.tv NOTICE #channel :Your message was not sent because it is identical.":             ErrDuplicate,
+		"@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #channel :This channel has been suspended.":                       ErrChannelSuspended,
+		"@msg-id=msg_followersonly :tmi.twitch.tv NOTICE #channel :This room is in 10 minutes followers-only mode.":            ErrFollowersOnly,
+		"@msg-id=msg_slowmode :tmi.twitch.tv NOTICE #channel :This room is in slow mode.":                                      ErrSlowMode,
+		"@msg-id=msg_something_new :tmi.twitch.tv NOTICE #channel :Your message was not sent.":                                 ErrMessageRejected,
+		":tmi.twitch.tv NOTICE * :Login authentication failed":                                                                 ErrLoginFailed,
+		":tmi.twitch.tv NOTICE * :Improperly formatted auth":                                                                   ErrLoginFailed,
+	}
+	for raw, expected := range cases {
+		err := noticeErr(t, raw)
+		if !errors.Is(err, expected) {
+			t.Errorf("Notice %q :: expected: %v, actual: %v", raw, expected, err)
+		}
+		var noticeErr *NoticeError
+		if !errors.As(err, &noticeErr) || noticeErr.Text == "" {
+			t.Errorf("Notice %q should return a *NoticeError: %#v", raw, err)
+		}
+	}
+}
+
+func TestNotice_ErrInformational(t *testing.T) {
+	if err := noticeErr(t, "@msg-id=emote_only_on :tmi.twitch.tv NOTICE #channel :This room is now in emote-only mode."); err != nil {
+		t.Errorf("Informational notices shouldn't be errors: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package twitchclient
 import (
 	"github.com/MoBlaa/gbc/twitchclient/modes"
 	"net/url"
+	"time"
 )
 
 // Option to be applied to a client. Options are used for a good maintainable and fluid construction of twitch clients.
@@ -65,3 +66,32 @@ func As(mode modes.MessageRateMode) Option {
 		client.mode = mode
 	}
 }
+
+// WithContinuationMarker sets the marker appended to the parts of messages which are split
+// because they exceed the length limit of twitch, e.g. " …".
+func WithContinuationMarker(marker string) Option {
+	return func(client *Client) {
+		client.continuation = marker
+	}
+}
+
+// WithDuplicateBypass enables varying chat messages identical to the previous one sent to the
+// channel within 30 seconds. Twitch rejects those otherwise. The messages are varied by an
+// invisible suffix.
+func WithDuplicateBypass() Option {
+	return func(client *Client) {
+		client.bypassDuplicates = true
+	}
+}
+
+// WithSpool persists messages accepted by the client in the file at the given path until they are
+// sent. Messages left after the process stopped are sent when connecting the next time, unless
+// they were accepted longer than maxAge ago. A maxAge of zero resends all messages. Messages
+// discarded by the client, e.g. because they expired, aren't resent. Messages sent with
+// Client.Send and reported as dropped when disconnecting aren't resent either.
+func WithSpool(path string, maxAge time.Duration) Option {
+	return func(client *Client) {
+		client.spoolPath = path
+		client.spoolMaxAge = maxAge
+	}
+}
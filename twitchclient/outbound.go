@@ -0,0 +1,129 @@
+package twitchclient
+
+import (
+	"errors"
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"sort"
+	"strings"
+)
+
+// ErrUnsafeText is returned if a message contains characters which would end the IRC line.
+// Sending such text would allow injecting additional commands.
+var ErrUnsafeText = errors.New("text contains line breaks or NUL characters")
+
+// Outbound is a message to be sent to twitch built from its components. Building the message
+// escapes tag values and rejects components which could inject additional commands.
+type Outbound struct {
+	// Tags sent with the message, e.g. `reply-parent-msg-id`. Values are escaped when building but
+	// must not contain line breaks or NUL characters.
+	Tags map[string]string
+	// Command of the message, e.g. PRIVMSG.
+	Command string
+	// Params are the middle parameters which must not contain spaces.
+	Params []string
+	// Trailing parameter, e.g. the text of a PRIVMSG. Omitted if empty.
+	Trailing string
+}
+
+// Build the raw IRC line of the message.
+func (mssg Outbound) Build() (string, error) {
+	if mssg.Command == "" || !isAlphaNumeric(mssg.Command) {
+		return "", fmt.Errorf("invalid command %q", mssg.Command)
+	}
+
+	var builder strings.Builder
+	if len(mssg.Tags) != 0 {
+		keys := make([]string, 0, len(mssg.Tags))
+		for key := range mssg.Tags {
+			if !isTagKey(key) {
+				return "", fmt.Errorf("invalid tag key %q", key)
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		builder.WriteByte('@')
+		for i, key := range keys {
+			if i > 0 {
+				builder.WriteByte(';')
+			}
+			builder.WriteString(key)
+			if value := mssg.Tags[key]; value != "" {
+				if !isSafe(value) {
+					return "", fmt.Errorf("invalid value of tag %q: %w", key, ErrUnsafeText)
+				}
+				builder.WriteByte('=')
+				builder.WriteString(escapeTagValue(value))
+			}
+		}
+		builder.WriteByte(' ')
+	}
+
+	builder.WriteString(mssg.Command)
+	for _, param := range mssg.Params {
+		if !isSafe(param) {
+			return "", fmt.Errorf("invalid parameter %q: %w", param, ErrUnsafeText)
+		}
+		if param == "" || strings.HasPrefix(param, ":") || strings.IndexByte(param, ' ') != -1 {
+			return "", fmt.Errorf("invalid parameter %q", param)
+		}
+		builder.WriteByte(' ')
+		builder.WriteString(param)
+	}
+	if mssg.Trailing != "" {
+		if !isSafe(mssg.Trailing) {
+			return "", ErrUnsafeText
+		}
+		builder.WriteString(" :")
+		builder.WriteString(mssg.Trailing)
+	}
+	return builder.String(), nil
+}
+
+// PlatformMessage builds the message and wraps it to be sent through the input channel of the client.
+func (mssg Outbound) PlatformMessage() (*gbc.PlatformMessage, error) {
+	raw, err := mssg.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &gbc.PlatformMessage{
+		Platform:   gbc.Twitch,
+		RawMessage: raw,
+	}, nil
+}
+
+// isSafe returns if the text doesn't contain characters ending an IRC line.
+func isSafe(text string) bool {
+	return strings.IndexAny(text, "\r\n\x00") == -1
+}
+
+// isSafeLine returns if the raw line only contains a line break at its end.
+func isSafeLine(raw string) bool {
+	return isSafe(strings.TrimRight(raw, "\r\n"))
+}
+
+func isAlphaNumeric(text string) bool {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isTagKey returns if the key is a valid tag key including client-only (`+`) and vendor prefixes.
+func isTagKey(key string) bool {
+	key = strings.TrimPrefix(key, "+")
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '/' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
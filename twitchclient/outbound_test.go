@@ -0,0 +1,66 @@
+package twitchclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutbound_Build(t *testing.T) {
+	raw, err := Outbound{
+		Tags:     map[string]string{"reply-parent-msg-id": "abc", "+client-nonce": "a b;c", "flag": ""},
+		Command:  "PRIVMSG",
+		Params:   []string{"#channel"},
+		Trailing: "hello : world",
+	}.Build()
+	if err != nil {
+		t.Fatalf("Failed to build message: %v", err)
+	}
+	expected := `@+client-nonce=a\sb\:c;flag;reply-parent-msg-id=abc PRIVMSG #channel :hello : world`
+	if raw != expected {
+		t.Errorf("Unexpected message :: expected: %q, actual: %q", expected, raw)
+	}
+
+	parsed, err := ParseIRC(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse built message: %v", err)
+	}
+	if value, _ := parsed.Tag("+client-nonce"); value != "a b;c" || parsed.Trailing != "hello : world" {
+		t.Errorf("Built message doesn't parse back correctly: %+v", parsed)
+	}
+
+	raw, err = Outbound{Command: "JOIN", Params: []string{"#channel"}}.Build()
+	if err != nil || raw != "JOIN #channel" {
+		t.Errorf("Unexpected message without tags and trailing: %q, %v", raw, err)
+	}
+}
+
+func TestOutbound_BuildUnsafe(t *testing.T) {
+	injections := []Outbound{
+		{Command: "PRIVMSG", Params: []string{"#channel"}, Trailing: "hi\r\nPART #channel"},
+		{Command: "PRIVMSG", Params: []string{"#channel"}, Trailing: "hi\nPRIVMSG #other :spam"},
+		{Command: "PRIVMSG", Params: []string{"#channel"}, Trailing: "hi\x00"},
+		{Command: "PRIVMSG", Params: []string{"#channel\r\nPART"}, Trailing: "hi"},
+		{Command: "PRIVMSG", Tags: map[string]string{"a": "x\x00y"}, Params: []string{"#channel"}, Trailing: "hi"},
+		{Command: "PRIVMSG", Tags: map[string]string{"a": "x\r\ny"}, Params: []string{"#channel"}, Trailing: "hi"},
+	}
+	for _, mssg := range injections {
+		if _, err := mssg.Build(); !errors.Is(err, ErrUnsafeText) {
+			t.Errorf("Expected ErrUnsafeText for %+v, got: %v", mssg, err)
+		}
+	}
+
+	invalids := []Outbound{
+		{Command: ""},
+		{Command: "PRIV MSG"},
+		{Command: "PRIVMSG", Params: []string{"#a b"}},
+		{Command: "PRIVMSG", Params: []string{":a"}},
+		{Command: "PRIVMSG", Params: []string{""}},
+		{Command: "PRIVMSG", Tags: map[string]string{"bad key": "x"}},
+		{Command: "PRIVMSG", Tags: map[string]string{"a=b": "x"}},
+	}
+	for _, mssg := range invalids {
+		if _, err := mssg.Build(); err == nil {
+			t.Errorf("Expected error for %+v", mssg)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package twitchclient
+
+import "fmt"
+
+// Priority of an outbound message. If the rate limits don't allow sending all queued messages,
+// messages of higher priority are sent first. Messages of the same priority keep their order.
+// Higher values denote higher priorities, so priorities can be compared.
+type Priority int
+
+const (
+	// PriorityBulk is used for messages which can wait, e.g. greetings of new chatters.
+	PriorityBulk Priority = iota - 1
+	// PriorityNormal is used for messages sent through the input channel and by Say, Reply,
+	// Action and Whisper.
+	PriorityNormal
+	// PriorityCritical is used for messages which have to be sent as soon as possible, e.g.
+	// moderation commands.
+	PriorityCritical
+)
+
+// Lanes of the outbound pipeline ordered from highest to lowest priority.
+var priorities = [...]Priority{PriorityCritical, PriorityNormal, PriorityBulk}
+
+func (priority Priority) String() string {
+	switch priority {
+	case PriorityCritical:
+		return "critical"
+	case PriorityNormal:
+		return "normal"
+	case PriorityBulk:
+		return "bulk"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(priority))
+	}
+}
+
+// lane returns the index of the lane messages of the priority are sent through.
+func (priority Priority) lane() (int, error) {
+	for i, candidate := range priorities {
+		if candidate == priority {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %v", priority)
+}
@@ -0,0 +1,31 @@
+package twitchclient
+
+import (
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"strings"
+)
+
+// NewReply creates a message sent to the channel as reply to the message with the given ID.
+// The reply is marked by the `reply-parent-msg-id` client tag, so the tags capability doesn't
+// have to be enabled to send replies.
+func NewReply(channel, parentID, text string) (*gbc.PlatformMessage, error) {
+	channel = strings.TrimPrefix(channel, "#")
+	if channel == "" {
+		return nil, fmt.Errorf("missing channel to reply in")
+	}
+	if parentID == "" {
+		return nil, fmt.Errorf("missing id of the message to reply to")
+	}
+	return Outbound{
+		Tags:     map[string]string{"reply-parent-msg-id": parentID},
+		Command:  "PRIVMSG",
+		Params:   []string{"#" + channel},
+		Trailing: text,
+	}.PlatformMessage()
+}
+
+// ReplyTo creates a message replying to the given chat message.
+func ReplyTo(parent *ChatMessage, text string) (*gbc.PlatformMessage, error) {
+	return NewReply(parent.Channel, parent.ID, text)
+}
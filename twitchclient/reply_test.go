@@ -0,0 +1,50 @@
+package twitchclient
+
+import (
+	"testing"
+)
+
+func TestChatMessage_ReplyThread(t *testing.T) {
+	chat := parseEvent(t, `@id=3;reply-parent-msg-body=second;reply-parent-msg-id=2;reply-parent-user-login=bar;reply-thread-parent-msg-id=1;reply-thread-parent-user-login=foo :user!user@user.tmi.twitch.tv PRIVMSG #channel :@bar third`).(*ChatMessage)
+	if chat.Reply == nil {
+		t.Fatal("Should contain reply parent")
+	}
+	if chat.Reply.MsgID != "2" || chat.Reply.UserLogin != "bar" || chat.Reply.Body != "second" {
+		t.Errorf("Unexpected parent: %+v", chat.Reply)
+	}
+	if chat.Reply.ThreadMsgID != "1" || chat.Reply.ThreadUserLogin != "foo" {
+		t.Errorf("Unexpected thread root: %+v", chat.Reply)
+	}
+}
+
+func TestReplyTo(t *testing.T) {
+	parent := parseEvent(t, "@id=b34ccfc7-4977-403a-8a94-33c6bac34fb8 :user!user@user.tmi.twitch.tv PRIVMSG #channel :hello").(*ChatMessage)
+	reply, err := ReplyTo(parent, "hi there")
+	if err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	expected := "@reply-parent-msg-id=b34ccfc7-4977-403a-8a94-33c6bac34fb8 PRIVMSG #channel :hi there"
+	if reply.RawMessage != expected {
+		t.Errorf("Unexpected reply :: expected: %q, actual: %q", expected, reply.RawMessage)
+	}
+
+	parsed, err := Message(*reply).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse reply: %v", err)
+	}
+	if value, _ := parsed.Tag("reply-parent-msg-id"); value != parent.ID || parsed.Channel() != "channel" || parsed.Trailing != "hi there" {
+		t.Errorf("Reply doesn't parse back correctly: %+v", parsed)
+	}
+}
+
+func TestNewReply_invalid(t *testing.T) {
+	if _, err := NewReply("channel", "", "text"); err == nil {
+		t.Error("Should fail without parent id")
+	}
+	if _, err := NewReply("", "id", "text"); err == nil {
+		t.Error("Should fail without channel")
+	}
+	if _, err := NewReply("channel", "id", "text\r\nPART #channel"); err == nil {
+		t.Error("Should fail for text containing line breaks")
+	}
+}
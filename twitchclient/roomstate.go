@@ -0,0 +1,180 @@
+package twitchclient
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// RoomState contains the chat settings of a channel.
+type RoomState struct {
+	// Channel the settings apply to without the leading '#'.
+	Channel string
+	// RoomID of the channel.
+	RoomID string
+	// EmoteOnly is set if only messages containing emotes are allowed.
+	EmoteOnly bool
+	// FollowersOnly is the number of minutes a user has to follow the channel to be allowed
+	// to chat. Zero means all followers are allowed to chat and -1 disables the mode.
+	FollowersOnly int
+	// R9K is set if messages have to be unique.
+	R9K bool
+	// Slow is the number of seconds users have to wait between sending messages. Zero if disabled.
+	Slow int
+	// SubsOnly is set if only subscribers are allowed to chat.
+	SubsOnly bool
+}
+
+// RoomStateUpdate is a ROOMSTATE message. Twitch sends all settings on joining a channel and only
+// the changed ones afterwards, so settings not contained in the message are nil.
+type RoomStateUpdate struct {
+	irc *IRCMessage
+
+	// Channel the settings apply to without the leading '#'.
+	Channel string
+	// RoomID of the channel.
+	RoomID string
+
+	EmoteOnly     *bool
+	FollowersOnly *int
+	R9K           *bool
+	Slow          *int
+	SubsOnly      *bool
+}
+
+// IRC returns the parsed message the update was created from.
+func (update *RoomStateUpdate) IRC() *IRCMessage {
+	return update.irc
+}
+
+// Apply the contained settings to the given state.
+func (update *RoomStateUpdate) Apply(state RoomState) RoomState {
+	state.Channel = update.Channel
+	if update.RoomID != "" {
+		state.RoomID = update.RoomID
+	}
+	if update.EmoteOnly != nil {
+		state.EmoteOnly = *update.EmoteOnly
+	}
+	if update.FollowersOnly != nil {
+		state.FollowersOnly = *update.FollowersOnly
+	}
+	if update.R9K != nil {
+		state.R9K = *update.R9K
+	}
+	if update.Slow != nil {
+		state.Slow = *update.Slow
+	}
+	if update.SubsOnly != nil {
+		state.SubsOnly = *update.SubsOnly
+	}
+	return state
+}
+
+// NewRoomStateUpdate creates a RoomStateUpdate from a parsed ROOMSTATE.
+func NewRoomStateUpdate(mssg *IRCMessage) (*RoomStateUpdate, error) {
+	if mssg.Command != "ROOMSTATE" {
+		return nil, fmt.Errorf("expected ROOMSTATE but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	update := &RoomStateUpdate{
+		irc:     mssg,
+		Channel: mssg.Channel(),
+		RoomID:  tags.RoomID(),
+	}
+
+	intTag := func(key string) (*int, error) {
+		raw, ok := tags[key]
+		if !ok {
+			return nil, nil
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s tag %q: %w", key, raw, err)
+		}
+		return &value, nil
+	}
+	boolTag := func(key string) *bool {
+		raw, ok := tags[key]
+		if !ok {
+			return nil
+		}
+		value := raw == "1"
+		return &value
+	}
+
+	var err error
+	if update.FollowersOnly, err = intTag("followers-only"); err != nil {
+		return nil, err
+	}
+	if update.Slow, err = intTag("slow"); err != nil {
+		return nil, err
+	}
+	update.EmoteOnly = boolTag("emote-only")
+	update.R9K = boolTag("r9k")
+	update.SubsOnly = boolTag("subs-only")
+	return update, nil
+}
+
+// RoomState returns the current settings of a joined channel. Returns false if no ROOMSTATE
+// was received for the channel yet.
+func (client *Client) RoomState(channel string) (RoomState, bool) {
+	return client.rooms.get(normalizeChannel(channel))
+}
+
+// OnRoomStateChange registers a handler which is called every time the settings of a channel change.
+// Handlers are called from the goroutine receiving messages so they shouldn't block.
+func (client *Client) OnRoomStateChange(handler func(RoomState)) {
+	client.rooms.onChange(handler)
+}
+
+// roomStates stores the RoomState of all joined channels.
+type roomStates struct {
+	lock     sync.RWMutex
+	states   map[string]RoomState
+	handlers []func(RoomState)
+}
+
+func newRoomStates() *roomStates {
+	return &roomStates{states: make(map[string]RoomState)}
+}
+
+func (rooms *roomStates) get(channel string) (RoomState, bool) {
+	rooms.lock.RLock()
+	defer rooms.lock.RUnlock()
+	state, ok := rooms.states[channel]
+	return state, ok
+}
+
+// update applies the update and notifies the handlers if the state of the channel changed.
+func (rooms *roomStates) update(update *RoomStateUpdate) {
+	rooms.lock.Lock()
+	old, known := rooms.states[update.Channel]
+	if !known {
+		// Followers-only is disabled until twitch says otherwise
+		old.FollowersOnly = -1
+	}
+	state := update.Apply(old)
+	rooms.states[update.Channel] = state
+	handlers := rooms.handlers
+	rooms.lock.Unlock()
+
+	if known && old == state {
+		return
+	}
+	for _, handler := range handlers {
+		handler(state)
+	}
+}
+
+func (rooms *roomStates) remove(channel string) {
+	rooms.lock.Lock()
+	delete(rooms.states, channel)
+	rooms.lock.Unlock()
+}
+
+func (rooms *roomStates) onChange(handler func(RoomState)) {
+	rooms.lock.Lock()
+	rooms.handlers = append(rooms.handlers, handler)
+	rooms.lock.Unlock()
+}
@@ -0,0 +1,50 @@
+package twitchclient
+
+import (
+	"testing"
+)
+
+func TestClient_RoomState(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot"})
+	var changes []RoomState
+	client.OnRoomStateChange(func(state RoomState) {
+		changes = append(changes, state)
+	})
+
+	if _, ok := client.RoomState("channel"); ok {
+		t.Fatal("Shouldn't know the state before receiving a ROOMSTATE")
+	}
+
+	trackRaw(t, client, "@emote-only=0;followers-only=-1;r9k=0;room-id=1337;slow=0;subs-only=0 :tmi.twitch.tv ROOMSTATE #channel")
+	expected := RoomState{Channel: "channel", RoomID: "1337", FollowersOnly: -1}
+	if state, ok := client.RoomState("channel"); !ok || state != expected {
+		t.Errorf("Unexpected initial state :: expected: %+v, actual: %+v", expected, state)
+	}
+
+	// Partial updates only change the contained settings
+	trackRaw(t, client, "@room-id=1337;slow=10 :tmi.twitch.tv ROOMSTATE #channel")
+	trackRaw(t, client, "@followers-only=30;room-id=1337 :tmi.twitch.tv ROOMSTATE #channel")
+	expected.Slow = 10
+	expected.FollowersOnly = 30
+	if state, _ := client.RoomState("#Channel"); state != expected {
+		t.Errorf("Unexpected updated state :: expected: %+v, actual: %+v", expected, state)
+	}
+
+	// Repeated settings don't trigger a notification
+	trackRaw(t, client, "@room-id=1337;slow=10 :tmi.twitch.tv ROOMSTATE #channel")
+	if len(changes) != 3 || changes[2] != expected {
+		t.Errorf("Unexpected notifications: %+v", changes)
+	}
+
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv PART #channel")
+	if _, ok := client.RoomState("channel"); ok {
+		t.Error("Should forget the state after leaving the channel")
+	}
+}
+
+func TestNewRoomStateUpdate_invalid(t *testing.T) {
+	mssg, _ := ParseIRC("@slow=fast :tmi.twitch.tv ROOMSTATE #channel")
+	if _, err := NewRoomStateUpdate(mssg); err == nil {
+		t.Error("Should fail for invalid slow tag")
+	}
+}
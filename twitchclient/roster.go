@@ -0,0 +1,211 @@
+package twitchclient
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chatter is a user present in a channel. Twitch only sends membership information if the
+// client is created with the `WithMembership()` option.
+type Chatter struct {
+	// Channel the user is present in without the leading '#'.
+	Channel string
+	// Login name of the user.
+	Login string
+	// Since is the time the user was first seen in the channel.
+	Since time.Time
+}
+
+// Chatters returns all users present in the channel sorted by their login name.
+func (client *Client) Chatters(channel string) []Chatter {
+	return client.roster.chatters(normalizeChannel(channel))
+}
+
+// IsPresent returns if the user is present in the channel.
+func (client *Client) IsPresent(channel, login string) bool {
+	return client.roster.present(normalizeChannel(channel), login)
+}
+
+// OnChatterJoin registers a handler which is called every time a user joins a channel.
+// Handlers are called from the goroutine receiving messages so they shouldn't block.
+func (client *Client) OnChatterJoin(handler func(Chatter)) {
+	client.roster.onJoin(handler)
+}
+
+// OnChatterLeave registers a handler which is called every time a user leaves a channel.
+// Handlers are called from the goroutine receiving messages so they shouldn't block.
+func (client *Client) OnChatterLeave(handler func(Chatter)) {
+	client.roster.onLeave(handler)
+}
+
+// roster keeps track of the users present in the joined channels.
+type roster struct {
+	lock     sync.RWMutex
+	channels map[string]map[string]time.Time
+	// names received with 353 replies which weren't finished by 366 yet.
+	names         map[string][]string
+	joinHandlers  []func(Chatter)
+	leaveHandlers []func(Chatter)
+}
+
+func newRoster() *roster {
+	return &roster{
+		channels: make(map[string]map[string]time.Time),
+		names:    make(map[string][]string),
+	}
+}
+
+func (rost *roster) chatters(channel string) []Chatter {
+	rost.lock.RLock()
+	defer rost.lock.RUnlock()
+	var chatters []Chatter
+	for login, since := range rost.channels[channel] {
+		chatters = append(chatters, Chatter{Channel: channel, Login: login, Since: since})
+	}
+	sort.Slice(chatters, func(i, j int) bool {
+		return chatters[i].Login < chatters[j].Login
+	})
+	return chatters
+}
+
+func (rost *roster) present(channel, login string) bool {
+	rost.lock.RLock()
+	defer rost.lock.RUnlock()
+	_, ok := rost.channels[channel][strings.ToLower(login)]
+	return ok
+}
+
+// join adds the users to the channel and notifies about the ones which weren't present before.
+func (rost *roster) join(channel string, logins ...string) {
+	now := time.Now()
+	var joined []Chatter
+	rost.lock.Lock()
+	chatters, ok := rost.channels[channel]
+	if !ok {
+		chatters = make(map[string]time.Time)
+		rost.channels[channel] = chatters
+	}
+	for _, login := range logins {
+		login = strings.ToLower(login)
+		if _, present := chatters[login]; present || login == "" {
+			continue
+		}
+		chatters[login] = now
+		joined = append(joined, Chatter{Channel: channel, Login: login, Since: now})
+	}
+	handlers := rost.joinHandlers
+	rost.lock.Unlock()
+
+	for _, chatter := range joined {
+		for _, handler := range handlers {
+			handler(chatter)
+		}
+	}
+}
+
+// leave removes the user from the channel and notifies if the user was present.
+func (rost *roster) leave(channel, login string) {
+	login = strings.ToLower(login)
+	rost.lock.Lock()
+	since, present := rost.channels[channel][login]
+	delete(rost.channels[channel], login)
+	handlers := rost.leaveHandlers
+	rost.lock.Unlock()
+
+	if !present {
+		return
+	}
+	chatter := Chatter{Channel: channel, Login: login, Since: since}
+	for _, handler := range handlers {
+		handler(chatter)
+	}
+}
+
+// addNames collects the users of a 353 reply until the list is finished by a 366 reply.
+func (rost *roster) addNames(channel string, logins []string) {
+	rost.lock.Lock()
+	rost.names[channel] = append(rost.names[channel], logins...)
+	rost.lock.Unlock()
+}
+
+// endNames merges the finished list of users into the channel. Users present before keep the time
+// they were first seen, users missing from the list left the channel. The login of the bot is skipped.
+func (rost *roster) endNames(channel, self string) {
+	now := time.Now()
+	self = strings.ToLower(self)
+	var joined, left []Chatter
+	rost.lock.Lock()
+	names := make(map[string]struct{}, len(rost.names[channel]))
+	for _, login := range rost.names[channel] {
+		if login = strings.ToLower(login); login != "" && login != self {
+			names[login] = struct{}{}
+		}
+	}
+	delete(rost.names, channel)
+	chatters, ok := rost.channels[channel]
+	if !ok {
+		chatters = make(map[string]time.Time)
+		rost.channels[channel] = chatters
+	}
+	for login, since := range chatters {
+		if _, listed := names[login]; !listed {
+			delete(chatters, login)
+			left = append(left, Chatter{Channel: channel, Login: login, Since: since})
+		}
+	}
+	for login := range names {
+		if _, present := chatters[login]; !present {
+			chatters[login] = now
+			joined = append(joined, Chatter{Channel: channel, Login: login, Since: now})
+		}
+	}
+	joinHandlers, leaveHandlers := rost.joinHandlers, rost.leaveHandlers
+	rost.lock.Unlock()
+
+	sort.Slice(left, func(i, j int) bool {
+		return left[i].Login < left[j].Login
+	})
+	sort.Slice(joined, func(i, j int) bool {
+		return joined[i].Login < joined[j].Login
+	})
+	for _, chatter := range left {
+		for _, handler := range leaveHandlers {
+			handler(chatter)
+		}
+	}
+	for _, chatter := range joined {
+		for _, handler := range joinHandlers {
+			handler(chatter)
+		}
+	}
+}
+
+// rejoin discards the unfinished list of users of the channel. The users already present are kept
+// until the new list is finished.
+func (rost *roster) rejoin(channel string) {
+	rost.lock.Lock()
+	delete(rost.names, channel)
+	rost.lock.Unlock()
+}
+
+// reset forgets all users of the channel without notifying.
+func (rost *roster) reset(channel string) {
+	rost.lock.Lock()
+	delete(rost.channels, channel)
+	delete(rost.names, channel)
+	rost.lock.Unlock()
+}
+
+func (rost *roster) onJoin(handler func(Chatter)) {
+	rost.lock.Lock()
+	rost.joinHandlers = append(rost.joinHandlers, handler)
+	rost.lock.Unlock()
+}
+
+func (rost *roster) onLeave(handler func(Chatter)) {
+	rost.lock.Lock()
+	rost.leaveHandlers = append(rost.leaveHandlers, handler)
+	rost.lock.Unlock()
+}
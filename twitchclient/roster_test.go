@@ -0,0 +1,92 @@
+package twitchclient
+
+import (
+	"testing"
+)
+
+func TestClient_Roster(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot"})
+	var joined, left []string
+	client.OnChatterJoin(func(chatter Chatter) {
+		joined = append(joined, chatter.Login)
+	})
+	client.OnChatterLeave(func(chatter Chatter) {
+		left = append(left, chatter.Login)
+	})
+
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv JOIN #channel")
+	trackRaw(t, client, ":bot.tmi.twitch.tv 353 bot = #channel :bot alice")
+	trackRaw(t, client, ":bot.tmi.twitch.tv 353 bot = #channel :bob")
+	if client.IsPresent("channel", "alice") {
+		t.Error("Names should only be added after the end of the list")
+	}
+	trackRaw(t, client, ":bot.tmi.twitch.tv 366 bot #channel :End of /NAMES list")
+	trackRaw(t, client, ":Carol!carol@carol.tmi.twitch.tv JOIN #channel")
+	trackRaw(t, client, ":alice!alice@alice.tmi.twitch.tv JOIN #channel")
+	trackRaw(t, client, ":bob!bob@bob.tmi.twitch.tv PART #channel")
+	trackRaw(t, client, ":dave!dave@dave.tmi.twitch.tv PART #channel")
+
+	chatters := client.Chatters("#channel")
+	var logins []string
+	for _, chatter := range chatters {
+		if chatter.Channel != "channel" || chatter.Since.IsZero() {
+			t.Errorf("Unexpected chatter: %+v", chatter)
+		}
+		logins = append(logins, chatter.Login)
+	}
+	if len(logins) != 2 || logins[0] != "alice" || logins[1] != "carol" {
+		t.Errorf("Unexpected chatters: %v", logins)
+	}
+	if !client.IsPresent("#Channel", "Carol") || client.IsPresent("channel", "bob") || client.IsPresent("other", "alice") {
+		t.Error("Unexpected presence")
+	}
+	if len(joined) != 3 || joined[2] != "carol" {
+		t.Errorf("Unexpected join events: %v", joined)
+	}
+	if len(left) != 1 || left[0] != "bob" {
+		t.Errorf("Unexpected leave events: %v", left)
+	}
+
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv PART #channel")
+	if len(client.Chatters("channel")) != 0 {
+		t.Error("Should forget chatters after leaving the channel")
+	}
+}
+
+func TestClient_RosterRejoin(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot"})
+	var joined, left []string
+	client.OnChatterJoin(func(chatter Chatter) {
+		joined = append(joined, chatter.Login)
+	})
+	client.OnChatterLeave(func(chatter Chatter) {
+		left = append(left, chatter.Login)
+	})
+
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv JOIN #channel")
+	trackRaw(t, client, ":bot.tmi.twitch.tv 353 bot = #channel :bot alice bob")
+	trackRaw(t, client, ":bot.tmi.twitch.tv 366 bot #channel :End of /NAMES list")
+	since := client.Chatters("channel")[0].Since
+
+	// Joining again, e.g. after reconnecting, merges the new list into the known chatters
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv JOIN #channel")
+	if !client.IsPresent("channel", "alice") {
+		t.Error("Chatters should be kept until the new list is finished")
+	}
+	trackRaw(t, client, ":bot.tmi.twitch.tv 353 bot = #channel :bot alice carol")
+	trackRaw(t, client, ":bot.tmi.twitch.tv 366 bot #channel :End of /NAMES list")
+
+	chatters := client.Chatters("#channel")
+	if len(chatters) != 2 || chatters[0].Login != "alice" || chatters[1].Login != "carol" {
+		t.Fatalf("Unexpected chatters: %+v", chatters)
+	}
+	if !chatters[0].Since.Equal(since) {
+		t.Errorf("Rejoining shouldn't reset the time chatters were first seen: %v != %v", chatters[0].Since, since)
+	}
+	if len(joined) != 3 || joined[0] != "alice" || joined[1] != "bob" || joined[2] != "carol" {
+		t.Errorf("Unexpected join events: %v", joined)
+	}
+	if len(left) != 1 || left[0] != "bob" {
+		t.Errorf("Unexpected leave events: %v", left)
+	}
+}
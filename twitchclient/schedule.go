@@ -0,0 +1,261 @@
+package twitchclient
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schedule is a message posted to a channel repeatedly. Scheduled messages are sent with
+// PriorityBulk through the rate limiter like all other messages. Messages are only posted while
+// the client is connected and the interval starts again after connecting.
+type Schedule struct {
+	// ID identifying the schedule. Assigned by Client.Schedule.
+	ID int
+	// Channel to post to without the leading '#'.
+	Channel string
+	// Text to post.
+	Text string
+	// Interval between two posts.
+	Interval time.Duration
+	// MinLines is the number of chat messages others have to send to the channel since the
+	// last post. If the interval passed without enough messages, the text is posted as soon as
+	// enough messages were sent.
+	MinLines int
+	// Paused is set if the schedule is paused.
+	Paused bool
+}
+
+// Schedule starts posting the message repeatedly. The first post happens after the interval
+// passed. Returns the ID of the schedule.
+func (client *Client) Schedule(schedule Schedule) (int, error) {
+	schedule.Channel = normalizeChannel(schedule.Channel)
+	if schedule.Channel == "" {
+		return 0, fmt.Errorf("missing channel to schedule messages for")
+	}
+	if schedule.Interval <= 0 {
+		return 0, fmt.Errorf("invalid interval %v", schedule.Interval)
+	}
+	if schedule.Text == "" || !isSafe(schedule.Text) {
+		return 0, fmt.Errorf("invalid text %q", schedule.Text)
+	}
+	return client.schedules.add(schedule), nil
+}
+
+// Schedules returns the messages scheduled for the channel ordered by their ID.
+func (client *Client) Schedules(channel string) []Schedule {
+	return client.schedules.list(normalizeChannel(channel))
+}
+
+// PauseSchedule stops posting the message until the schedule is resumed.
+func (client *Client) PauseSchedule(id int) error {
+	return client.schedules.pause(id)
+}
+
+// ResumeSchedule continues posting a paused message. The next post happens after the interval passed.
+func (client *Client) ResumeSchedule(id int) error {
+	return client.schedules.resume(id)
+}
+
+// Unschedule stops posting the message.
+func (client *Client) Unschedule(id int) error {
+	return client.schedules.remove(id)
+}
+
+type scheduled struct {
+	Schedule
+	// lines sent to the channel since the last post.
+	lines int
+	// due is set if the interval passed but not enough lines were sent.
+	due bool
+	// posting is set while the last post waits for the limiter to accept it.
+	posting bool
+	timer   *time.Timer
+	// generation is increased every time the timer is replaced, so outdated timers are ignored.
+	generation int
+}
+
+// schedules keeps track of the scheduled messages and the lines sent to their channels.
+type schedules struct {
+	lock    sync.Mutex
+	nextID  int
+	entries map[int]*scheduled
+	post    func(channel, text string)
+	// active is set while the client is connected. Timers only run while active.
+	active bool
+}
+
+func newSchedules(post func(channel, text string)) *schedules {
+	return &schedules{
+		nextID:  1,
+		entries: make(map[int]*scheduled),
+		post:    post,
+	}
+}
+
+func (sched *schedules) add(schedule Schedule) int {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	schedule.ID = sched.nextID
+	sched.nextID++
+	entry := &scheduled{Schedule: schedule}
+	sched.entries[schedule.ID] = entry
+	if !schedule.Paused && sched.active {
+		sched.start(entry)
+	}
+	return schedule.ID
+}
+
+func (sched *schedules) list(channel string) []Schedule {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	var list []Schedule
+	for _, entry := range sched.entries {
+		if entry.Channel == channel {
+			list = append(list, entry.Schedule)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+func (sched *schedules) pause(id int) error {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	entry, ok := sched.entries[id]
+	if !ok {
+		return fmt.Errorf("unknown schedule %d", id)
+	}
+	entry.Paused = true
+	entry.due = false
+	sched.stop(entry)
+	return nil
+}
+
+func (sched *schedules) resume(id int) error {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	entry, ok := sched.entries[id]
+	if !ok {
+		return fmt.Errorf("unknown schedule %d", id)
+	}
+	if entry.Paused {
+		entry.Paused = false
+		if sched.active {
+			sched.start(entry)
+		}
+	}
+	return nil
+}
+
+func (sched *schedules) remove(id int) error {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	entry, ok := sched.entries[id]
+	if !ok {
+		return fmt.Errorf("unknown schedule %d", id)
+	}
+	sched.stop(entry)
+	delete(sched.entries, id)
+	return nil
+}
+
+// line records a chat message sent to the channel and posts due messages which waited for it.
+func (sched *schedules) line(channel string) {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	for _, entry := range sched.entries {
+		if entry.Channel != channel || entry.Paused {
+			continue
+		}
+		entry.lines++
+		if entry.due && entry.lines >= entry.MinLines && !entry.posting {
+			sched.send(entry)
+			sched.start(entry)
+		}
+	}
+}
+
+// start the timer of the entry. Has to be called while holding the lock.
+func (sched *schedules) start(entry *scheduled) {
+	sched.stop(entry)
+	generation := entry.generation
+	entry.timer = time.AfterFunc(entry.Interval, func() {
+		sched.elapsed(entry, generation)
+	})
+}
+
+// stop the timer of the entry. Has to be called while holding the lock.
+func (sched *schedules) stop(entry *scheduled) {
+	entry.generation++
+	if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+	}
+}
+
+// elapsed is called when the interval of the entry passed.
+func (sched *schedules) elapsed(entry *scheduled, generation int) {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	if entry.generation != generation || sched.entries[entry.ID] != entry {
+		return
+	}
+	if entry.posting {
+		// Posts pile up if the limiter is busy with messages of higher priority
+		log.Printf("Skipping scheduled message %d as the last post wasn't sent yet", entry.ID)
+	} else if entry.lines >= entry.MinLines {
+		sched.send(entry)
+	} else {
+		entry.due = true
+	}
+	sched.start(entry)
+}
+
+// connected starts the timers of all schedules which aren't paused.
+func (sched *schedules) connected() {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	sched.active = true
+	for _, entry := range sched.entries {
+		if !entry.Paused {
+			sched.start(entry)
+		}
+	}
+}
+
+// disconnected stops the timers of all schedules until the client connects again.
+func (sched *schedules) disconnected() {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+	sched.active = false
+	for _, entry := range sched.entries {
+		entry.due = false
+		sched.stop(entry)
+	}
+}
+
+// send posts the message of the entry. Has to be called while holding the lock.
+func (sched *schedules) send(entry *scheduled) {
+	entry.lines = 0
+	entry.due = false
+	entry.posting = true
+	// Posting blocks until the limiter accepts the message
+	go func() {
+		sched.post(entry.Channel, entry.Text)
+		sched.lock.Lock()
+		defer sched.lock.Unlock()
+		entry.posting = false
+	}()
+}
+
+// postScheduled is used by the schedules to post messages.
+func (client *Client) postScheduled(channel, text string) {
+	if err := client.say(channel, text, PriorityBulk); err != nil {
+		log.Printf("failed to post scheduled message to %s: %v", channel, err)
+	}
+}
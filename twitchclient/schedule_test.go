@@ -0,0 +1,179 @@
+package twitchclient
+
+import (
+	"testing"
+	"time"
+)
+
+// capturePosts replaces posting scheduled messages of the client with sending them to the
+// returned channel. The schedules are active as if the client was connected.
+func capturePosts(client *Client) <-chan string {
+	posts := make(chan string, 10)
+	client.schedules = newSchedules(func(channel, text string) {
+		posts <- channel + ": " + text
+	})
+	client.schedules.connected()
+	return posts
+}
+
+func expectPost(t *testing.T, posts <-chan string, expected string) {
+	t.Helper()
+	select {
+	case post := <-posts:
+		if post != expected {
+			t.Fatalf("Unexpected post :: expected: %q, actual: %q", expected, post)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for %q", expected)
+	}
+}
+
+func expectNoPost(t *testing.T, posts <-chan string, wait time.Duration) {
+	t.Helper()
+	select {
+	case post := <-posts:
+		t.Fatalf("Unexpected post: %q", post)
+	case <-time.After(wait):
+	}
+}
+
+// drainPosts discards a post which was already in flight.
+func drainPosts(posts <-chan string) {
+	select {
+	case <-posts:
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestClient_Schedule(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot", Token: "oauth:token"})
+	posts := capturePosts(client)
+
+	id, err := client.Schedule(Schedule{Channel: "#Bot", Text: "Follow the stream!", Interval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+	expectPost(t, posts, "bot: Follow the stream!")
+	expectPost(t, posts, "bot: Follow the stream!")
+
+	if err := client.PauseSchedule(id); err != nil {
+		t.Fatalf("Failed to pause: %v", err)
+	}
+	drainPosts(posts)
+	expectNoPost(t, posts, 60*time.Millisecond)
+	if schedules := client.Schedules("bot"); len(schedules) != 1 || !schedules[0].Paused || schedules[0].ID != id {
+		t.Errorf("Unexpected schedules: %+v", schedules)
+	}
+
+	if err := client.ResumeSchedule(id); err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	expectPost(t, posts, "bot: Follow the stream!")
+
+	if err := client.Unschedule(id); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	drainPosts(posts)
+	expectNoPost(t, posts, 60*time.Millisecond)
+	if schedules := client.Schedules("bot"); len(schedules) != 0 {
+		t.Errorf("Unexpected schedules: %+v", schedules)
+	}
+	if err := client.PauseSchedule(id); err == nil {
+		t.Error("Expected error for removed schedule")
+	}
+}
+
+func TestClient_ScheduleMinLines(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot", Token: "oauth:token"})
+	posts := capturePosts(client)
+
+	if _, err := client.Schedule(Schedule{Channel: "bot", Text: "hello", Interval: 20 * time.Millisecond, MinLines: 2}); err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+	trackRaw(t, client, ":viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #bot :one")
+	// Messages of the bot itself and other channels don't count
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv PRIVMSG #bot :hello")
+	trackRaw(t, client, ":viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #other :two")
+	expectNoPost(t, posts, 60*time.Millisecond)
+
+	// Due messages are posted as soon as enough lines were sent
+	trackRaw(t, client, ":viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #bot :three")
+	expectPost(t, posts, "bot: hello")
+	expectNoPost(t, posts, 60*time.Millisecond)
+}
+
+func TestClient_ScheduleInvalid(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot", Token: "oauth:token"})
+	invalid := []Schedule{
+		{Text: "hello", Interval: time.Minute},
+		{Channel: "bot", Interval: time.Minute},
+		{Channel: "bot", Text: "hello"},
+		{Channel: "bot", Text: "hello\r\nPART #bot", Interval: time.Minute},
+	}
+	for _, schedule := range invalid {
+		if _, err := client.Schedule(schedule); err == nil {
+			t.Errorf("Expected error for %+v", schedule)
+		}
+	}
+
+	first, _ := client.Schedule(Schedule{Channel: "bot", Text: "first", Interval: time.Hour, Paused: true})
+	second, _ := client.Schedule(Schedule{Channel: "bot", Text: "second", Interval: time.Hour})
+	defer client.Unschedule(second)
+	schedules := client.Schedules("#bot")
+	if len(schedules) != 2 || schedules[0].ID != first || schedules[1].ID != second || schedules[1].Text != "second" {
+		t.Errorf("Unexpected schedules: %+v", schedules)
+	}
+}
+
+func TestClient_SchedulePosts(t *testing.T) {
+	client, in, _, conn := connectFake(t)
+	defer close(in)
+
+	id, err := client.Schedule(Schedule{Channel: "bot", Text: "Follow the stream!", Interval: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to schedule: %v", err)
+	}
+	defer client.Unschedule(id)
+	conn.expect(t, "PRIVMSG #bot :Follow the stream!")
+}
+
+func TestSchedules_postInFlight(t *testing.T) {
+	release := make(chan struct{})
+	calls := make(chan string, 10)
+	sched := newSchedules(func(channel, text string) {
+		calls <- text
+		<-release
+	})
+	sched.connected()
+	id := sched.add(Schedule{Channel: "bot", Text: "hello", Interval: 10 * time.Millisecond})
+	defer sched.remove(id)
+
+	expectPost(t, calls, "hello")
+	// The limiter doesn't accept the post, so further posts are skipped
+	expectNoPost(t, calls, 60*time.Millisecond)
+	close(release)
+	expectPost(t, calls, "hello")
+}
+
+func TestSchedules_disconnected(t *testing.T) {
+	posts := make(chan string, 10)
+	sched := newSchedules(func(channel, text string) {
+		posts <- channel + ": " + text
+	})
+	// Nothing is posted before connecting
+	id := sched.add(Schedule{Channel: "bot", Text: "hello", Interval: 10 * time.Millisecond})
+	defer sched.remove(id)
+	expectNoPost(t, posts, 40*time.Millisecond)
+
+	sched.connected()
+	expectPost(t, posts, "bot: hello")
+	sched.disconnected()
+	drainPosts(posts)
+	expectNoPost(t, posts, 40*time.Millisecond)
+
+	sched.connected()
+	expectPost(t, posts, "bot: hello")
+	if schedules := sched.list("bot"); len(schedules) != 1 || schedules[0].Paused {
+		t.Errorf("Disconnecting shouldn't pause schedules: %+v", schedules)
+	}
+}
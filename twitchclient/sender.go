@@ -0,0 +1,77 @@
+package twitchclient
+
+import (
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"strings"
+)
+
+// Channel used to send whispers with the `/w` chat command.
+const whisperChannel = "#jtv"
+
+// Say sends the text to the channel. Like all messages sent to twitch, the message passes the
+// limiter first, so Say blocks until the limiter accepts the message. Texts exceeding 500
+// characters are split and sent as multiple messages. Options like TTL drop the message if it
+// can't be sent in time, but unlike Send the delivery isn't reported.
+func (client *Client) Say(channel, text string, opts ...SendOption) error {
+	return client.say(channel, text, PriorityNormal, opts...)
+}
+
+func (client *Client) say(channel, text string, priority Priority, opts ...SendOption) error {
+	channel = strings.TrimPrefix(channel, "#")
+	if channel == "" {
+		return fmt.Errorf("missing channel to send to")
+	}
+	if text == "" {
+		return fmt.Errorf("missing text to send")
+	}
+	mssg, err := Outbound{
+		Command:  "PRIVMSG",
+		Params:   []string{"#" + channel},
+		Trailing: text,
+	}.PlatformMessage()
+	if err != nil {
+		return err
+	}
+	return client.enqueueWith(mssg, priority, opts)
+}
+
+// Reply sends the text to the channel of the message as reply to it.
+func (client *Client) Reply(parent *ChatMessage, text string, opts ...SendOption) error {
+	mssg, err := ReplyTo(parent, text)
+	if err != nil {
+		return err
+	}
+	return client.enqueueWith(mssg, PriorityNormal, opts)
+}
+
+// Action sends the text to the channel as action, which is shown like the `/me` chat command.
+func (client *Client) Action(channel, text string, opts ...SendOption) error {
+	return client.Say(channel, "\x01ACTION "+text+"\x01", opts...)
+}
+
+// Whisper sends the text to the user as whisper. Whispers are limited by the whisper limits
+// and the number of accounts whispered per day instead of the chat limits.
+func (client *Client) Whisper(user, text string, opts ...SendOption) error {
+	user = strings.ToLower(strings.TrimPrefix(user, "@"))
+	if user == "" || strings.IndexByte(user, ' ') != -1 {
+		return fmt.Errorf("invalid user %q to whisper to", user)
+	}
+	if text == "" {
+		return fmt.Errorf("missing text to whisper")
+	}
+	mssg, err := Outbound{
+		Command:  "PRIVMSG",
+		Params:   []string{whisperChannel},
+		Trailing: "/w " + user + " " + text,
+	}.PlatformMessage()
+	if err != nil {
+		return err
+	}
+	return client.enqueueWith(mssg, PriorityNormal, opts)
+}
+
+// enqueueWith passes the message to the limiter after applying the options.
+func (client *Client) enqueueWith(mssg *gbc.PlatformMessage, priority Priority, opts []SendOption) error {
+	return client.enqueue(newSendOptions(opts).tag(mssg), priority)
+}
@@ -0,0 +1,119 @@
+package twitchclient
+
+import (
+	"errors"
+	"github.com/MoBlaa/gbc"
+	"testing"
+	"time"
+)
+
+func TestClient_Senders(t *testing.T) {
+	client, in, _, conn := connectFake(t)
+	defer close(in)
+
+	if err := client.Say("#bot", "hello"); err != nil {
+		t.Fatalf("Failed to say: %v", err)
+	}
+	conn.expect(t, "PRIVMSG #bot :hello")
+
+	if err := client.Whisper("@Someone", "psst"); err != nil {
+		t.Fatalf("Failed to whisper: %v", err)
+	}
+	conn.expect(t, "PRIVMSG #jtv :/w someone psst")
+
+	if err := client.Action("bot", "waves"); err != nil {
+		t.Fatalf("Failed to send action: %v", err)
+	}
+	conn.expect(t, "PRIVMSG #bot :\x01ACTION waves\x01")
+
+	parent := &ChatMessage{Channel: "bot", ID: "abc-123"}
+	if err := client.Reply(parent, "hi back"); err != nil {
+		t.Fatalf("Failed to reply: %v", err)
+	}
+	conn.expect(t, "@reply-parent-msg-id=abc-123 PRIVMSG #bot :hi back")
+
+	// Messages of the application and the senders share the connection
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :raw"}
+	conn.expect(t, "PRIVMSG #bot :raw")
+}
+
+func TestClient_SendersDeadline(t *testing.T) {
+	client, in, _, conn := connectFake(t)
+	defer close(in)
+
+	expired := Deadline(time.Now().Add(-time.Second))
+	if err := client.Say("bot", "too late", expired); err != nil {
+		t.Fatalf("Failed to say: %v", err)
+	}
+	if err := client.Whisper("someone", "too late", expired); err != nil {
+		t.Fatalf("Failed to whisper: %v", err)
+	}
+	if err := client.Action("bot", "is too late", expired); err != nil {
+		t.Fatalf("Failed to send action: %v", err)
+	}
+	if err := client.Reply(&ChatMessage{Channel: "bot", ID: "abc-123"}, "too late", expired); err != nil {
+		t.Fatalf("Failed to reply: %v", err)
+	}
+	// Messages of the application carry their deadline in a tag
+	deadline := time.Now().Add(-time.Second).Format(time.RFC3339Nano)
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@" + DeadlineTag + "=" + deadline + " PRIVMSG #bot :too late"}
+
+	// The deadline tag isn't sent to twitch
+	if err := client.Say("bot", "in time", TTL(time.Minute)); err != nil {
+		t.Fatalf("Failed to say: %v", err)
+	}
+	conn.expect(t, "PRIVMSG #bot :in time")
+	deadline = time.Now().Add(time.Minute).Format(time.RFC3339Nano)
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@" + DeadlineTag + "=" + deadline + ";reply-parent-msg-id=abc-123 PRIVMSG #bot :raw in time"}
+	conn.expect(t, "@reply-parent-msg-id=abc-123 PRIVMSG #bot :raw in time")
+}
+
+func TestClient_SendersInvalid(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot", Token: "oauth:token"})
+
+	if err := client.Say("bot", "hi\r\nPART #bot"); !errors.Is(err, ErrUnsafeText) {
+		t.Errorf("Expected ErrUnsafeText but got: %v", err)
+	}
+	if err := client.Whisper("some one", "hi"); err == nil {
+		t.Error("Expected error for invalid user")
+	}
+	if err := client.Say("", "hi"); err == nil {
+		t.Error("Expected error for missing channel")
+	}
+	if err := client.Say("bot", ""); err == nil {
+		t.Error("Expected error for missing text")
+	}
+	if err := client.Say("bot", "hi"); err != ErrDisconnected {
+		t.Errorf("Expected ErrDisconnected but got: %v", err)
+	}
+}
+
+func TestClient_SendersAfterDisconnect(t *testing.T) {
+	client, in, _, conn := connectFake(t)
+
+	close(in)
+	select {
+	case _, ok := <-conn.lines:
+		if ok {
+			t.Fatal("Connection should only be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the connection to close")
+	}
+	if err := client.Say("bot", "hi"); err != ErrDisconnected {
+		t.Errorf("Expected ErrDisconnected but got: %v", err)
+	}
+}
+
+func TestClient_SendWithPriority(t *testing.T) {
+	client, in, _, conn := connectFake(t)
+	defer close(in)
+
+	if _, err := client.SendWithPriority(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :hi"}, Priority(42)); err == nil {
+		t.Error("Expected error for unknown priority")
+	}
+	if _, err := client.SendWithPriority(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=a PRIVMSG #bot :hi"}, PriorityCritical); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	conn.expect(t, "@client-nonce=a PRIVMSG #bot :hi")
+}
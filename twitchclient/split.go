@@ -0,0 +1,165 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum number of characters of a chat message or whisper. Twitch drops longer messages.
+const maxMessageLength = 500
+
+// splitter splits chat messages and whispers exceeding the length limit of twitch into multiple
+// messages. Each part passes the following pipeline steps as its own message.
+type splitter struct {
+	// MaxLength of the text of a message in characters.
+	MaxLength int
+	// Marker appended to all but the last part, e.g. " …". Counts towards the length.
+	Marker string
+	// Split is called with the original message and the number of parts if a message is split.
+	Split func(mssg *gbc.PlatformMessage, parts int)
+}
+
+// split returns the parts of the message. Messages which don't exceed the limit and messages
+// other than PRIVMSG are returned unchanged.
+func (split *splitter) split(mssg *gbc.PlatformMessage) []*gbc.PlatformMessage {
+	unchanged := []*gbc.PlatformMessage{mssg}
+	// The text can't be longer than the whole message
+	if mssg.Platform != gbc.Twitch || utf8.RuneCountInString(mssg.RawMessage) <= split.MaxLength {
+		return unchanged
+	}
+	if command, _ := peekCommand(mssg.RawMessage); command != "PRIVMSG" {
+		return unchanged
+	}
+	parsed, err := ParseIRC(mssg.RawMessage)
+	if err != nil || !parsed.HasTrailing {
+		return unchanged
+	}
+
+	// Whispers and actions are split without their command, which is repeated for every part
+	prefix, text, suffix := "", parsed.Trailing, ""
+	if user, whisper, ok := whisperCommand(text); ok {
+		prefix, text = text[:3]+user+" ", whisper
+	} else if strings.HasPrefix(text, "\x01ACTION ") && strings.HasSuffix(text, "\x01") && len(text) > 9 {
+		prefix, text, suffix = "\x01ACTION ", text[8:len(text)-1], "\x01"
+	}
+	if utf8.RuneCountInString(text) <= split.MaxLength {
+		return unchanged
+	}
+
+	texts := splitText(text, split.MaxLength, split.Marker)
+	parts := make([]*gbc.PlatformMessage, 0, len(texts))
+	for _, part := range texts {
+		built, err := rebuild(parsed, prefix+part+suffix)
+		if err != nil {
+			log.Printf("failed to split message %q: %v", mssg.RawMessage, err)
+			return unchanged
+		}
+		parts = append(parts, built)
+	}
+	if split.Split != nil {
+		split.Split(mssg, len(parts))
+	}
+	return parts
+}
+
+// splitText splits the text into parts of at most max characters. Parts end at spaces if
+// possible and never in the middle of a character or grapheme cluster. All but the last
+// part end with the marker.
+func splitText(text string, max int, marker string) []string {
+	if utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+	budget := max - utf8.RuneCountInString(marker)
+	if budget < 1 {
+		budget, marker = max, ""
+	}
+
+	var parts []string
+	for remaining := utf8.RuneCountInString(text); remaining > max; {
+		part, rest := splitOnce(text, budget)
+		parts = append(parts, part+marker)
+		remaining -= utf8.RuneCountInString(text[:len(text)-len(rest)])
+		text = rest
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// splitOnce splits a part of at most max characters from the text. The part ends before the
+// last space if there is one, otherwise at the last grapheme cluster boundary.
+func splitOnce(text string, max int) (part, rest string) {
+	cut, space, count, regional := 0, -1, 0, 0
+	var prev rune
+	for i, r := range text {
+		if i > 0 && isGraphemeBoundary(prev, r, regional) {
+			cut = i
+			if r == ' ' || prev == ' ' {
+				space = i
+			}
+		}
+		if count == max {
+			break
+		}
+		if isRegionalIndicator(r) {
+			regional++
+		} else {
+			regional = 0
+		}
+		prev = r
+		count++
+	}
+
+	if space != -1 {
+		if part := strings.TrimRight(text[:space], " "); part != "" {
+			return part, strings.TrimLeft(text[space:], " ")
+		}
+	}
+	if cut == 0 {
+		// A single grapheme cluster exceeds the limit, so it has to be cut between characters
+		for i := range text {
+			if count == 0 {
+				cut = i
+				break
+			}
+			count--
+		}
+	}
+	return text[:cut], text[cut:]
+}
+
+// isGraphemeBoundary returns if a grapheme cluster ends between the characters prev and r.
+// regional is the number of consecutive regional indicators ending with prev. This covers
+// combining marks, emoji sequences and flags, but not all rules of Unicode (UAX #29).
+func isGraphemeBoundary(prev, r rune, regional int) bool {
+	switch {
+	case prev == '\u200d':
+		// Zero width joiner combines the surrounding emojis
+		return false
+	case isExtending(r):
+		return false
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		// Flags consist of pairs of regional indicators
+		return regional%2 == 0
+	default:
+		return true
+	}
+}
+
+// isExtending returns if the character extends the previous grapheme cluster.
+func isExtending(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Variation_Selector) ||
+		r == '\u200d' ||
+		// Emoji skin tone modifiers
+		r >= 0x1F3FB && r <= 0x1F3FF ||
+		// Tag characters used in subdivision flags
+		r >= 0xE0020 && r <= 0xE007F
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,75 @@
+package twitchclient
+
+import (
+	"github.com/MoBlaa/gbc"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		max      int
+		marker   string
+		expected []string
+	}{
+		{"short", "hello world", 20, "", []string{"hello world"}},
+		{"words", "one two three four", 9, "", []string{"one two", "three", "four"}},
+		{"marker", "one two three four five", 10, " …", []string{"one two …", "three …", "four five"}},
+		{"spaces", "one    two", 5, "", []string{"one", "two"}},
+		{"long word", "abcdefghij", 4, "", []string{"abcd", "efgh", "ij"}},
+		{"multibyte", "äöüäöü", 4, "", []string{"äöüä", "öü"}},
+		{"combining", "abécd", 3, "", []string{"ab", "éc", "d"}},
+		{"zwj", "ab👩‍👩‍👧", 5, "", []string{"ab", "👩‍👩‍👧"}},
+		{"flags", "a🇩🇪🇫🇷", 4, "", []string{"a🇩🇪", "🇫🇷"}},
+		{"skin tone", "abc👍🏽d", 4, "", []string{"abc", "👍🏽d"}},
+		{"oversized cluster", "é́́́", 3, "", []string{"é́", "́́"}},
+	}
+	for _, c := range cases {
+		actual := splitText(c.text, c.max, c.marker)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s :: expected: %q, actual: %q", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestSplitText_length(t *testing.T) {
+	text := strings.Repeat("Kappa Keepo 👩‍👩‍👧 ", 100)
+	parts := splitText(text, maxMessageLength, " …")
+	if len(parts) < 2 {
+		t.Fatalf("Expected text to be split: %d", len(parts))
+	}
+	for _, part := range parts {
+		if count := utf8.RuneCountInString(part); count > maxMessageLength {
+			t.Errorf("Part exceeds the limit: %d", count)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("Part contains invalid UTF-8: %q", part)
+		}
+	}
+}
+
+func TestSplitter_split(t *testing.T) {
+	split := splitter{MaxLength: 5}
+	cases := map[string][]string{
+		"PRIVMSG #bot :one two":                        {"PRIVMSG #bot :one", "PRIVMSG #bot :two"},
+		"@reply-parent-msg-id=a PRIVMSG #bot :one two": {"@reply-parent-msg-id=a PRIVMSG #bot :one", "@reply-parent-msg-id=a PRIVMSG #bot :two"},
+		"PRIVMSG #jtv :/w someone one two":             {"PRIVMSG #jtv :/w someone one", "PRIVMSG #jtv :/w someone two"},
+		"PRIVMSG #bot :\x01ACTION one two\x01":         {"PRIVMSG #bot :\x01ACTION one\x01", "PRIVMSG #bot :\x01ACTION two\x01"},
+		"PRIVMSG #jtv :/w someone hi":                  {"PRIVMSG #jtv :/w someone hi"},
+		"PRIVMSG #bot :hi":                             {"PRIVMSG #bot :hi"},
+		"JOIN #channel1,#channel2":                     {"JOIN #channel1,#channel2"},
+	}
+	for raw, expected := range cases {
+		var actual []string
+		for _, part := range split.split(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: raw}) {
+			actual = append(actual, part.RawMessage)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Split of %q :: expected: %q, actual: %q", raw, expected, actual)
+		}
+	}
+}
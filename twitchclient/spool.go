@@ -0,0 +1,422 @@
+package twitchclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/MoBlaa/gbc"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of records in the spool file after which it is compacted, if most of them are done.
+const spoolCompactThreshold = 1000
+
+// Maximum length of a record in the spool file. Longer records are skipped when reading the file.
+const maxSpoolRecord = 1024 * 1024
+
+// spoolRecord is a line of the spool file. Records either add a message or mark it as done.
+type spoolRecord struct {
+	ID   uint64 `json:"id"`
+	Done bool   `json:"done,omitempty"`
+	// At is the time the message was accepted in nanoseconds since the unix epoch.
+	At int64 `json:"at,omitempty"`
+	// Deadline after which the message isn't sent anymore in nanoseconds since the unix epoch.
+	// Zero if the message doesn't expire.
+	Deadline int64    `json:"deadline,omitempty"`
+	Priority Priority `json:"priority,omitempty"`
+	Raw      string   `json:"raw,omitempty"`
+}
+
+// expired returns if the deadline of the message passed.
+func (record spoolRecord) expired(now time.Time) bool {
+	return record.Deadline != 0 && !now.Before(time.Unix(0, record.Deadline))
+}
+
+// spool persists messages accepted by the client in an append-only file until they are sent or
+// dropped, so they are sent after a restart if the process stops before. Messages are persisted
+// as soon as they are read from the input and split, so each part is a record on its own. All
+// methods can be called on a nil spool, which doesn't persist anything.
+type spool struct {
+	path string
+
+	lock   sync.Mutex
+	file   *os.File
+	closed bool
+	nextID uint64
+	// records written to the file since it was compacted.
+	records int
+	// pending contains the records of messages which weren't done yet.
+	pending map[uint64]spoolRecord
+	// ids of the messages in the pipeline. Every spooled message is a copy only known to the
+	// pipeline, so messages sent multiple times by the application have their own ids.
+	ids map[*gbc.PlatformMessage]uint64
+	// replay contains the messages left from the previous run per lane.
+	replay [][]*gbc.PlatformMessage
+}
+
+// openSpool reads the messages left in the spool file and compacts it. Messages accepted longer
+// than maxAge ago or whose deadline passed are discarded. A maxAge of zero keeps all messages.
+func openSpool(path string, maxAge time.Duration) (*spool, error) {
+	sp := &spool{
+		path:    path,
+		nextID:  1,
+		pending: make(map[uint64]spoolRecord),
+		ids:     make(map[*gbc.PlatformMessage]uint64),
+		replay:  make([][]*gbc.PlatformMessage, len(priorities)),
+	}
+	if err := sp.read(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	records := make([]spoolRecord, 0, len(sp.pending))
+	for id, record := range sp.pending {
+		if maxAge > 0 && now.Sub(time.Unix(0, record.At)) > maxAge {
+			log.Printf("Discarding spooled message older than %v: %q", maxAge, record.Raw)
+			delete(sp.pending, id)
+			continue
+		}
+		if record.expired(now) {
+			log.Printf("Discarding spooled message which expired at %v: %q", time.Unix(0, record.Deadline), record.Raw)
+			delete(sp.pending, id)
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	for _, record := range records {
+		lane, err := record.Priority.lane()
+		if err != nil {
+			lane, _ = PriorityNormal.lane()
+		}
+		mssg := &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: record.Raw}
+		sp.ids[mssg] = record.ID
+		sp.replay[lane] = append(sp.replay[lane], mssg)
+	}
+	if len(records) != 0 {
+		log.Printf("Resending %d spooled messages", len(records))
+	}
+
+	if err := sp.compact(); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// read the records of the spool file. A missing file is treated as empty.
+func (sp *spool) read() error {
+	file, err := os.Open(sp.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, maxSpoolRecord)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Records are only that long if the file is corrupted, so they are skipped like invalid ones
+			log.Printf("Skipping record of spool %s exceeding %d bytes", sp.path, maxSpoolRecord)
+			for err == bufio.ErrBufferFull {
+				_, err = reader.ReadSlice('\n')
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read spool: %w", err)
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read spool: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			sp.readRecord(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// readRecord applies a line of the spool file. Invalid records are skipped.
+func (sp *spool) readRecord(line []byte) {
+	var record spoolRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		// The last record is incomplete if the process stopped while writing it
+		log.Printf("Skipping invalid record of spool %s: %v", sp.path, err)
+		return
+	}
+	if record.Done {
+		delete(sp.pending, record.ID)
+	} else {
+		sp.pending[record.ID] = record
+	}
+	if record.ID >= sp.nextID {
+		sp.nextID = record.ID + 1
+	}
+}
+
+// replayed returns the messages left from the previous run which are sent with the priority.
+// The messages are only returned once.
+func (sp *spool) replayed(priority Priority) []*gbc.PlatformMessage {
+	lane, err := priority.lane()
+	if sp == nil || err != nil {
+		return nil
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	replay := sp.replay[lane]
+	sp.replay[lane] = nil
+	return replay
+}
+
+// add persists the messages before they are passed to the limiter. Returns the messages to pass
+// on, which are copies of the spooled ones.
+func (sp *spool) add(mssgs []*gbc.PlatformMessage, priority Priority, deadline time.Time) []*gbc.PlatformMessage {
+	if sp == nil {
+		return mssgs
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if sp.closed {
+		return mssgs
+	}
+	var expires int64
+	if !deadline.IsZero() {
+		expires = deadline.UnixNano()
+	}
+	now := time.Now().UnixNano()
+
+	spooled := make([]*gbc.PlatformMessage, 0, len(mssgs))
+	records := make([]spoolRecord, 0, len(mssgs))
+	for _, mssg := range mssgs {
+		if mssg.Platform != gbc.Twitch {
+			spooled = append(spooled, mssg)
+			continue
+		}
+		copied := *mssg
+		record := spoolRecord{
+			ID:       sp.nextID,
+			At:       now,
+			Deadline: expires,
+			Priority: priority,
+			Raw:      mssg.RawMessage,
+		}
+		sp.nextID++
+		spooled = append(spooled, &copied)
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return spooled
+	}
+	if err := sp.append(records...); err != nil {
+		log.Printf("failed to spool messages %q: %v", records[0].Raw, err)
+		return spooled
+	}
+	i := 0
+	for _, mssg := range spooled {
+		if mssg.Platform == gbc.Twitch {
+			sp.pending[records[i].ID] = records[i]
+			sp.ids[mssg] = records[i].ID
+			i++
+		}
+	}
+	return spooled
+}
+
+// isExpired returns if the deadline of the spooled message passed. The spool knows the deadline
+// of replayed messages, which aren't tracked as deliveries anymore.
+func (sp *spool) isExpired(mssg *gbc.PlatformMessage, now time.Time) bool {
+	if sp == nil {
+		return false
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	id, ok := sp.ids[mssg]
+	return ok && sp.pending[id].expired(now)
+}
+
+// done removes the message from the spool after it was sent or dropped.
+func (sp *spool) done(mssg *gbc.PlatformMessage) {
+	if sp == nil {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	id, ok := sp.ids[mssg]
+	if !ok || sp.closed {
+		return
+	}
+	delete(sp.ids, mssg)
+	sp.remove(id)
+}
+
+// discard removes the messages with the given nonces from the spool, e.g. because they were
+// reported as dropped to the application, which might send them again.
+func (sp *spool) discard(nonces []string) {
+	if sp == nil || len(nonces) == 0 {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if sp.closed {
+		return
+	}
+	discarded := make(map[string]bool, len(nonces))
+	for _, nonce := range nonces {
+		discarded[nonce] = true
+	}
+	var ids []uint64
+	for mssg, id := range sp.ids {
+		if discarded[nonceOf(mssg.RawMessage)] {
+			delete(sp.ids, mssg)
+			ids = append(ids, id)
+		}
+	}
+	sp.remove(ids...)
+}
+
+// remove marks the records as done with a single write. Has to be called while holding the lock.
+func (sp *spool) remove(ids ...uint64) {
+	if len(ids) == 0 {
+		return
+	}
+	records := make([]spoolRecord, 0, len(ids))
+	for _, id := range ids {
+		delete(sp.pending, id)
+		records = append(records, spoolRecord{ID: id, Done: true})
+	}
+	if err := sp.append(records...); err != nil {
+		log.Printf("failed to remove messages %v from spool: %v", ids, err)
+		return
+	}
+	if sp.records >= spoolCompactThreshold && sp.records > 2*len(sp.pending) {
+		if err := sp.compact(); err != nil {
+			log.Printf("failed to compact spool: %v", err)
+		}
+	}
+}
+
+// replaced records that the message is sent as a different one, e.g. after varying duplicates.
+func (sp *spool) replaced(original, replacement *gbc.PlatformMessage) {
+	if sp == nil {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if id, ok := sp.ids[original]; ok {
+		delete(sp.ids, original)
+		sp.ids[replacement] = id
+	}
+}
+
+// close the spool file. Messages which weren't sent yet are kept for the next run.
+func (sp *spool) close() {
+	if sp == nil {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if sp.closed {
+		return
+	}
+	sp.closed = true
+	if err := sp.file.Close(); err != nil {
+		log.Printf("failed to close spool: %v", err)
+	}
+}
+
+// append writes the records to the file and waits until they are stored. Has to be called while
+// holding the lock.
+func (sp *spool) append(records ...spoolRecord) error {
+	var lines []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if _, err := sp.file.Write(lines); err != nil {
+		return err
+	}
+	sp.records += len(records)
+	return sp.file.Sync()
+}
+
+// compact replaces the file with one only containing the pending messages. Has to be called
+// while holding the lock.
+func (sp *spool) compact() error {
+	records := make([]spoolRecord, 0, len(sp.pending))
+	for _, record := range sp.pending {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+
+	// The new file replaces the old one only after it was written completely
+	tmp := sp.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to compact spool: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err = encoder.Encode(record); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, sp.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to compact spool: %w", err)
+	}
+	syncDir(filepath.Dir(sp.path))
+
+	if sp.file != nil {
+		_ = sp.file.Close()
+	}
+	sp.file, err = os.OpenFile(sp.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %w", err)
+	}
+	sp.records = len(records)
+	return nil
+}
+
+// syncDir stores the renaming of the spool file. Not supported on all platforms, so errors are ignored.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	_ = dir.Sync()
+	_ = dir.Close()
+}
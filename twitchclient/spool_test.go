@@ -0,0 +1,332 @@
+package twitchclient
+
+import (
+	"encoding/json"
+	"github.com/MoBlaa/gbc"
+	"github.com/MoBlaa/gbc/twitchclient/modes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempSpool returns the path of a spool file in a new temporary directory and a function removing it.
+func tempSpool(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	return filepath.Join(dir, "outbound.spool"), func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// writeSpool creates a spool file containing the given records.
+func writeSpool(t *testing.T, path string, records ...spoolRecord) {
+	t.Helper()
+	var content []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			t.Fatalf("Failed to encode record: %v", err)
+		}
+		content = append(append(content, line...), '\n')
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to write spool: %v", err)
+	}
+}
+
+// replayed returns the raw messages replayed for the lane of the priority.
+func replayed(sp *spool, priority Priority) []string {
+	var raws []string
+	for _, mssg := range sp.replayed(priority) {
+		raws = append(raws, mssg.RawMessage)
+	}
+	return raws
+}
+
+// spoolRaw spools a message with the raw content and returns the spooled copy.
+func spoolRaw(sp *spool, raw string, priority Priority, deadline time.Time) *gbc.PlatformMessage {
+	mssg := &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: raw}
+	return sp.add([]*gbc.PlatformMessage{mssg}, priority, deadline)[0]
+}
+
+func TestSpool_replay(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	sent := spoolRaw(sp, "PRIVMSG #bot :sent", PriorityNormal, time.Time{})
+	// Parts of split messages are spooled together
+	sp.add([]*gbc.PlatformMessage{
+		{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :first"},
+		{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :second"},
+	}, PriorityNormal, time.Time{})
+	spoolRaw(sp, "PRIVMSG #bot :/timeout spammer", PriorityCritical, time.Time{})
+	sp.done(sent)
+	sp.close()
+
+	// Simulate the process stopping while writing a record
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	_, _ = file.WriteString(`{"id":5,"raw":"PRIV`)
+	_ = file.Close()
+
+	sp, err = openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	replay := sp.replayed(PriorityNormal)
+	if len(replay) != 2 || replay[0].RawMessage != "PRIVMSG #bot :first" || replay[1].RawMessage != "PRIVMSG #bot :second" {
+		t.Fatalf("Unexpected messages replayed: %v", replay)
+	}
+	if actual := strings.Join(replayed(sp, PriorityCritical), "|"); actual != "PRIVMSG #bot :/timeout spammer" {
+		t.Errorf("Unexpected critical messages replayed: %q", actual)
+	}
+	if actual := replayed(sp, PriorityNormal); len(actual) != 0 {
+		t.Errorf("Messages replayed twice: %q", actual)
+	}
+	if sp.nextID != 5 {
+		t.Errorf("IDs should continue after the last complete record, next ID: %d", sp.nextID)
+	}
+
+	// Replayed messages are removed once sent
+	sp.done(replay[0])
+	sp.done(replay[1])
+	spoolRaw(sp, "PRIVMSG #bot :third", PriorityBulk, time.Time{})
+	sp.close()
+
+	sp, err = openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer sp.close()
+	if actual := strings.Join(replayed(sp, PriorityBulk), "|"); actual != "PRIVMSG #bot :third" {
+		t.Errorf("Unexpected messages replayed: %q", actual)
+	}
+	if actual := replayed(sp, PriorityCritical); len(actual) != 1 {
+		t.Errorf("Unexpected critical messages replayed: %q", actual)
+	}
+}
+
+func TestSpool_oversizedRecord(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	writeSpool(t, path,
+		spoolRecord{ID: 1, Raw: "PRIVMSG #bot :" + strings.Repeat("a", maxSpoolRecord)},
+		spoolRecord{ID: 2, Raw: "PRIVMSG #bot :kept"},
+	)
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Oversized records shouldn't prevent opening the spool: %v", err)
+	}
+	defer sp.close()
+	if actual := strings.Join(replayed(sp, PriorityNormal), "|"); actual != "PRIVMSG #bot :kept" {
+		t.Errorf("Unexpected messages replayed: %q", actual)
+	}
+}
+
+func TestSpool_sameMessageTwice(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+
+	// Applications may send the same message repeatedly
+	ping := &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :ping"}
+	first := sp.add([]*gbc.PlatformMessage{ping}, PriorityNormal, time.Time{})[0]
+	second := sp.add([]*gbc.PlatformMessage{ping}, PriorityNormal, time.Time{})[0]
+	if first == second || first == ping {
+		t.Fatal("Spooled messages should be distinct copies")
+	}
+	sp.done(first)
+	sp.done(second)
+	sp.close()
+
+	sp, err = openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer sp.close()
+	if actual := replayed(sp, PriorityNormal); len(actual) != 0 {
+		t.Errorf("Sent messages left in spool: %q", actual)
+	}
+}
+
+func TestSpool_expired(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+	now := time.Now()
+	writeSpool(t, path,
+		spoolRecord{ID: 1, At: now.Add(-time.Hour).UnixNano(), Raw: "PRIVMSG #bot :old"},
+		spoolRecord{ID: 2, At: now.UnixNano(), Raw: "PRIVMSG #bot :new"},
+		// Unknown priorities are sent with normal priority
+		spoolRecord{ID: 3, At: now.UnixNano(), Priority: Priority(42), Raw: "PRIVMSG #bot :unknown"},
+		spoolRecord{ID: 4, At: now.UnixNano(), Deadline: now.Add(-time.Second).UnixNano(), Raw: "PRIVMSG #bot :expired"},
+		spoolRecord{ID: 5, At: now.UnixNano(), Deadline: now.Add(time.Minute).UnixNano(), Raw: "PRIVMSG #bot :expiring"},
+	)
+
+	sp, err := openSpool(path, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	defer sp.close()
+	replay := sp.replayed(PriorityNormal)
+	var raws []string
+	for _, mssg := range replay {
+		raws = append(raws, mssg.RawMessage)
+	}
+	if actual := strings.Join(raws, "|"); actual != "PRIVMSG #bot :new|PRIVMSG #bot :unknown|PRIVMSG #bot :expiring" {
+		t.Fatalf("Unexpected messages replayed: %q", actual)
+	}
+
+	// The deadline of replayed messages is still applied
+	if sp.isExpired(replay[2], now) || sp.isExpired(replay[0], now.Add(time.Hour)) {
+		t.Error("Message expired before its deadline")
+	}
+	if !sp.isExpired(replay[2], now.Add(time.Minute)) {
+		t.Error("Message didn't expire after its deadline")
+	}
+}
+
+func TestSpool_discard(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	spoolRaw(sp, "@client-nonce=a PRIVMSG #bot :reported", PriorityNormal, time.Time{})
+	spoolRaw(sp, "PRIVMSG #bot :kept", PriorityNormal, time.Time{})
+	spoolRaw(sp, "@client-nonce=b PRIVMSG #bot :also reported", PriorityNormal, time.Time{})
+	records := sp.records
+	sp.discard([]string{"a", "b"})
+	if sp.records != records+2 {
+		t.Errorf("Expected 2 done records, got %d", sp.records-records)
+	}
+	sp.close()
+
+	sp, err = openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer sp.close()
+	if actual := strings.Join(replayed(sp, PriorityNormal), "|"); actual != "PRIVMSG #bot :kept" {
+		t.Errorf("Unexpected messages replayed: %q", actual)
+	}
+}
+
+func TestSpool_compact(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	defer sp.close()
+
+	spoolRaw(sp, "PRIVMSG #bot :kept", PriorityBulk, time.Time{})
+	for i := 0; i < spoolCompactThreshold; i++ {
+		sp.done(spoolRaw(sp, "PRIVMSG #bot :hi", PriorityNormal, time.Time{}))
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read spool: %v", err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines >= spoolCompactThreshold {
+		t.Errorf("Spool wasn't compacted: %d records", lines)
+	}
+	if !strings.Contains(string(content), "PRIVMSG #bot :kept") {
+		t.Errorf("Pending message removed by compaction: %q", content)
+	}
+}
+
+func TestClient_Spool(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+	writeSpool(t, path, spoolRecord{ID: 1, At: time.Now().UnixNano(), Raw: "PRIVMSG #bot :left over"})
+
+	_, in, out, conn := connectFake(t, WithSpool(path, time.Hour))
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for range out {
+		}
+	}()
+	conn.expect(t, "PRIVMSG #bot :left over")
+
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :new"}
+	conn.expect(t, "PRIVMSG #bot :new")
+	// The client disconnects after sending all messages of the closed input
+	close(in)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the client to disconnect")
+	}
+
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer sp.close()
+	if actual := replayed(sp, PriorityNormal); len(actual) != 0 {
+		t.Errorf("Sent messages left in spool: %q", actual)
+	}
+}
+
+func TestClient_SpoolDisconnect(t *testing.T) {
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+	client, in, out, _ := connectFake(t, WithSpool(path, time.Hour), As(modes.KNOWN))
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for range out {
+		}
+	}()
+	client.lock.Lock()
+	finished := client.finished
+	client.lock.Unlock()
+
+	// Both messages wait for the limiter when disconnecting
+	in <- &gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "PRIVMSG #bot :queued"}
+	result, err := client.Send(&gbc.PlatformMessage{Platform: gbc.Twitch, RawMessage: "@client-nonce=a PRIVMSG #bot :reported"})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	client.Disconnect()
+	if delivery := awaitDelivery(t, result); delivery.Status != DeliveryDropped || delivery.Err != ErrDisconnected {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+	<-closed
+	// The spool is closed after the pipeline is drained
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the pipeline to finish")
+	}
+
+	// Only the message not reported as dropped is sent again
+	sp, err := openSpool(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer sp.close()
+	if actual := strings.Join(replayed(sp, PriorityNormal), "|"); actual != "PRIVMSG #bot :queued" {
+		t.Errorf("Unexpected messages replayed: %q", actual)
+	}
+}
@@ -0,0 +1,188 @@
+package twitchclient
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tags contains the IRCv3 tags of a message with already unescaped values.
+// Tags are only sent by twitch if the client is created with the `WithTags()` option.
+type Tags map[string]string
+
+// Tags returns the parsed tags of the message. They are parsed on first access and the returned
+// map is shared between calls, so it must not be modified. Returns an empty map if the message has no tags.
+func (mssg *IRCMessage) Tags() Tags {
+	mssg.tagsOnce.Do(func() {
+		mssg.tags = parseTags(mssg.RawTags)
+	})
+	return mssg.tags
+}
+
+// Tag returns the unescaped value of a single tag and if it is present. Doesn't parse all tags,
+// so it is cheaper than `Tags()` if only few tags are needed. Like `Tags()`, the last value is
+// returned if the key is present multiple times.
+func (mssg *IRCMessage) Tag(key string) (string, bool) {
+	raw := mssg.RawTags
+	value, found := "", false
+	for raw != "" {
+		var pair string
+		pair, raw = nextTag(raw)
+		if !strings.HasPrefix(pair, key) {
+			continue
+		}
+		if len(pair) == len(key) {
+			value, found = "", true
+		} else if pair[len(key)] == '=' {
+			value, found = pair[len(key)+1:], true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	return unescapeTagValue(value), true
+}
+
+// nextTag splits the first `key=value` pair from the raw tags.
+func nextTag(raw string) (pair, rest string) {
+	end := strings.IndexByte(raw, ';')
+	if end == -1 {
+		return raw, ""
+	}
+	return raw[:end], raw[end+1:]
+}
+
+func parseTags(raw string) Tags {
+	tags := make(Tags, strings.Count(raw, ";")+1)
+	for raw != "" {
+		var pair string
+		pair, raw = nextTag(raw)
+		if pair == "" {
+			continue
+		}
+		key, value := pair, ""
+		if eq := strings.IndexByte(pair, '='); eq != -1 {
+			key, value = pair[:eq], unescapeTagValue(pair[eq+1:])
+		}
+		tags[key] = value
+	}
+	return tags
+}
+
+// unescapeTagValue reverts the escaping of tag values as defined in the IRCv3 spec.
+// Invalid escapes drop the backslash and a trailing backslash is removed.
+func unescapeTagValue(value string) string {
+	if strings.IndexByte(value, '\\') == -1 {
+		return value
+	}
+	var builder strings.Builder
+	builder.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			builder.WriteByte(value[i])
+			continue
+		}
+		i++
+		if i == len(value) {
+			break
+		}
+		switch value[i] {
+		case ':':
+			builder.WriteByte(';')
+		case 's':
+			builder.WriteByte(' ')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'n':
+			builder.WriteByte('\n')
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+// escapeTagValue escapes a tag value as defined in the IRCv3 spec.
+func escapeTagValue(value string) string {
+	if strings.IndexAny(value, "; \\\r\n") == -1 {
+		return value
+	}
+	var builder strings.Builder
+	builder.Grow(len(value) + 4)
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case ';':
+			builder.WriteString("\\:")
+		case ' ':
+			builder.WriteString("\\s")
+		case '\\':
+			builder.WriteString("\\\\")
+		case '\r':
+			builder.WriteString("\\r")
+		case '\n':
+			builder.WriteString("\\n")
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+func (tags Tags) flag(key string) bool {
+	return tags[key] == "1"
+}
+
+// DisplayName of the user which sent the message.
+func (tags Tags) DisplayName() string {
+	return tags["display-name"]
+}
+
+// Color of the user in hex format (e.g. #0000FF). Empty if the user never set a color.
+func (tags Tags) Color() string {
+	return tags["color"]
+}
+
+// UserID of the user which sent the message.
+func (tags Tags) UserID() string {
+	return tags["user-id"]
+}
+
+// RoomID of the channel the message was sent to.
+func (tags Tags) RoomID() string {
+	return tags["room-id"]
+}
+
+// ID of the message.
+func (tags Tags) ID() string {
+	return tags["id"]
+}
+
+// SentAt returns the time the message was sent at by parsing `tmi-sent-ts`.
+// Returns the zero time if the tag is missing or invalid.
+func (tags Tags) SentAt() time.Time {
+	millis, err := strconv.ParseInt(tags["tmi-sent-ts"], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, millis*int64(time.Millisecond))
+}
+
+// Mod returns if the user is a moderator of the channel.
+func (tags Tags) Mod() bool {
+	return tags.flag("mod")
+}
+
+// Subscriber returns if the user is a subscriber of the channel.
+func (tags Tags) Subscriber() bool {
+	return tags.flag("subscriber")
+}
+
+// FirstMessage returns if this is the first message of the user in the channel.
+func (tags Tags) FirstMessage() bool {
+	return tags.flag("first-msg")
+}
+
+// ReturningChatter returns if the user is a returning chatter in the channel.
+func (tags Tags) ReturningChatter() bool {
+	return tags.flag("returning-chatter")
+}
@@ -0,0 +1,108 @@
+package twitchclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnescapeTagValue(t *testing.T) {
+	cases := map[string]string{
+		"plain":            "plain",
+		`hello\sworld`:     "hello world",
+		`semi\:colon`:      "semi;colon",
+		`back\\slash`:      `back\slash`,
+		`line\r\nbreak`:    "line\r\nbreak",
+		`invalid\xescape`:  "invalidxescape",
+		`trailing\`:        "trailing",
+		`\s\:\\\s`:         ` ;\ `,
+		`Thanks\sfor\s5\:`: "Thanks for 5;",
+	}
+	for escaped, expected := range cases {
+		if actual := unescapeTagValue(escaped); actual != expected {
+			t.Errorf("Unescaping %q :: expected: %q, actual: %q", escaped, expected, actual)
+		}
+	}
+}
+
+func TestIRCMessage_Tags(t *testing.T) {
+	mssg, err := ParseIRC(`@badge-info=;color=#1E90FF;display-name=Some\sOne;emotes=;first-msg=1;id=b34ccfc7-4977-403a-8a94-33c6bac34fb8;mod=0;returning-chatter=0;room-id=1337;subscriber=1;tmi-sent-ts=1507246572675;user-id=4242;flag :someone!someone@someone.tmi.twitch.tv PRIVMSG #channel :hi`)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	tags := mssg.Tags()
+
+	if tags.DisplayName() != "Some One" {
+		t.Errorf("Unexpected display-name: %q", tags.DisplayName())
+	}
+	if tags.Color() != "#1E90FF" {
+		t.Errorf("Unexpected color: %q", tags.Color())
+	}
+	if tags.UserID() != "4242" || tags.RoomID() != "1337" {
+		t.Errorf("Unexpected ids: user-id=%q, room-id=%q", tags.UserID(), tags.RoomID())
+	}
+	if tags.ID() != "b34ccfc7-4977-403a-8a94-33c6bac34fb8" {
+		t.Errorf("Unexpected id: %q", tags.ID())
+	}
+	if expected := time.Unix(1507246572, 675*int64(time.Millisecond)); !tags.SentAt().Equal(expected) {
+		t.Errorf("Unexpected tmi-sent-ts :: expected: %v, actual: %v", expected, tags.SentAt())
+	}
+	if tags.Mod() || !tags.Subscriber() || !tags.FirstMessage() || tags.ReturningChatter() {
+		t.Errorf("Unexpected flags: %v", tags)
+	}
+	if value, ok := mssg.Tag("flag"); !ok || value != "" {
+		t.Errorf("Tag without value should be present and empty: %q, %v", value, ok)
+	}
+	if _, ok := mssg.Tag("missing"); ok {
+		t.Error("Missing tag shouldn't be present")
+	}
+}
+
+func TestIRCMessage_TagDuplicateKeys(t *testing.T) {
+	mssg, err := ParseIRC(`@client-nonce=first;id=a;client-nonce=second\sone;flag=set;flag :tmi.twitch.tv USERSTATE #channel`)
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	// The last value wins like in Tags()
+	for _, key := range []string{"client-nonce", "id", "flag"} {
+		value, ok := mssg.Tag(key)
+		if expected := mssg.Tags()[key]; !ok || value != expected {
+			t.Errorf("Tag(%q) :: expected: %q, actual: %q", key, expected, value)
+		}
+	}
+	if value, _ := mssg.Tag("client-nonce"); value != "second one" {
+		t.Errorf("Unexpected client-nonce: %q", value)
+	}
+}
+
+func TestTags_SentAtMissing(t *testing.T) {
+	if !(Tags{}).SentAt().IsZero() || !(Tags{"tmi-sent-ts": "abc"}).SentAt().IsZero() {
+		t.Error("Missing or invalid tmi-sent-ts should return the zero time")
+	}
+}
+
+func TestEscapeTagValue(t *testing.T) {
+	for _, value := range []string{"plain", "with space", "semi;colon", `back\slash`, "line\r\nbreak", ` ;\ `} {
+		if unescaped := unescapeTagValue(escapeTagValue(value)); unescaped != value {
+			t.Errorf("Escaping %q doesn't revert :: actual: %q", value, unescaped)
+		}
+	}
+	if escaped := escapeTagValue("a b;c"); escaped != `a\sb\:c` {
+		t.Errorf("Unexpected escaped value: %q", escaped)
+	}
+}
+
+func BenchmarkIRCMessage_Tags(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mssg, _ := ParseIRC(benchmarkMessage)
+		mssg.Tags()
+	}
+}
+
+func BenchmarkIRCMessage_Tag(b *testing.B) {
+	mssg, _ := ParseIRC(benchmarkMessage)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		mssg.Tag("user-id")
+	}
+}
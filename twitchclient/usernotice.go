@@ -0,0 +1,251 @@
+package twitchclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SubPlan is the tier of a subscription.
+type SubPlan string
+
+const (
+	// PlanPrime is a subscription paid with Prime Gaming.
+	PlanPrime SubPlan = "Prime"
+	// PlanTier1 is a tier 1 subscription.
+	PlanTier1 SubPlan = "1000"
+	// PlanTier2 is a tier 2 subscription.
+	PlanTier2 SubPlan = "2000"
+	// PlanTier3 is a tier 3 subscription.
+	PlanTier3 SubPlan = "3000"
+)
+
+// UserNotice is a USERNOTICE sent to a channel. Notices with a known `msg-id` are converted
+// into the typed events embedding the UserNotice. Unknown ones are returned as is.
+type UserNotice struct {
+	irc *IRCMessage
+
+	// Channel the notice was sent to without the leading '#'.
+	Channel string
+	// MsgID identifies the type of the notice, e.g. "sub" or "raid".
+	MsgID string
+	// Login name of the user which caused the notice.
+	Login string
+	// DisplayName of the user which caused the notice.
+	DisplayName string
+	// UserID of the user which caused the notice.
+	UserID string
+	// SystemMsg is the text twitch shows for the notice.
+	SystemMsg string
+	// Text is the optional message the user attached to the notice.
+	Text string
+	// Params contains all `msg-param-*` tags with the prefix removed.
+	Params map[string]string
+	// Tags of the notice.
+	Tags Tags
+}
+
+// UserNoticeEvent is implemented by UserNotice and all typed notices embedding it.
+type UserNoticeEvent interface {
+	Event
+	// Base returns the common fields of all notices.
+	Base() *UserNotice
+}
+
+// IRC returns the parsed message the notice was created from.
+func (notice *UserNotice) IRC() *IRCMessage {
+	return notice.irc
+}
+
+// Base returns the notice itself, which is embedded by all typed notices.
+func (notice *UserNotice) Base() *UserNotice {
+	return notice
+}
+
+func (notice *UserNotice) intParam(key string) int {
+	value, _ := strconv.Atoi(notice.Params[key])
+	return value
+}
+
+// Sub is sent for a new subscription (msg-id "sub") or a resubscription (msg-id "resub").
+type Sub struct {
+	UserNotice
+	// Resub is set for resubscriptions.
+	Resub bool
+	// CumulativeMonths the user is subscribed.
+	CumulativeMonths int
+	// StreakMonths the user is subscribed in a row. Zero if the user doesn't share it.
+	StreakMonths int
+	// Plan of the subscription.
+	Plan SubPlan
+	// PlanName is the display name of the subscription plan.
+	PlanName string
+}
+
+// SubGift is sent when a user gifts a subscription to another user (msg-id "subgift").
+type SubGift struct {
+	UserNotice
+	// Months the recipient is subscribed in total.
+	Months int
+	// GiftMonths is the number of months gifted.
+	GiftMonths int
+	// RecipientLogin is the login name of the user receiving the gift.
+	RecipientLogin string
+	// RecipientDisplayName is the display name of the user receiving the gift.
+	RecipientDisplayName string
+	// RecipientID is the user id of the user receiving the gift.
+	RecipientID string
+	// Plan of the gifted subscription.
+	Plan SubPlan
+	// PlanName is the display name of the subscription plan.
+	PlanName string
+}
+
+// MysteryGift is sent when a user gifts subscriptions to random users of the channel
+// (msg-id "submysterygift").
+type MysteryGift struct {
+	UserNotice
+	// Count of gifted subscriptions.
+	Count int
+	// SenderCount is the total number of subscriptions the user gifted in the channel.
+	SenderCount int
+	// Plan of the gifted subscriptions.
+	Plan SubPlan
+}
+
+// GiftPaidUpgrade is sent when a user continues a gifted subscription
+// (msg-id "giftpaidupgrade" or "anongiftpaidupgrade").
+type GiftPaidUpgrade struct {
+	UserNotice
+	// Anonymous is set if the original gift was anonymous.
+	Anonymous bool
+	// SenderLogin is the login name of the user which gifted the original subscription.
+	SenderLogin string
+	// SenderName is the display name of the user which gifted the original subscription.
+	SenderName string
+	// PromoName is the name of the running promotion, if any.
+	PromoName string
+	// PromoGiftTotal is the number of gifts the user gave during the promotion.
+	PromoGiftTotal int
+}
+
+// Raid is sent when a channel raids the channel (msg-id "raid").
+type Raid struct {
+	UserNotice
+	// RaiderLogin is the login name of the raiding broadcaster.
+	RaiderLogin string
+	// RaiderDisplayName is the display name of the raiding broadcaster.
+	RaiderDisplayName string
+	// ViewerCount is the number of viewers joining with the raid.
+	ViewerCount int
+}
+
+// Ritual is sent for rituals like a new chatter saying hello for the first time (msg-id "ritual").
+type Ritual struct {
+	UserNotice
+	// Name of the ritual, e.g. "new_chatter".
+	Name string
+}
+
+// Announcement is sent when a moderator or the broadcaster announces a message (msg-id "announcement").
+type Announcement struct {
+	UserNotice
+	// Color of the announcement, e.g. "PRIMARY" or "BLUE".
+	Color string
+}
+
+// BitsBadgeTier is sent when a user earns a new bits badge tier (msg-id "bitsbadgetier").
+type BitsBadgeTier struct {
+	UserNotice
+	// Threshold is the tier of the earned badge, e.g. 1000.
+	Threshold int
+}
+
+// NewUserNotice creates a typed event from a parsed USERNOTICE. Notices with unknown `msg-id`
+// are returned as *UserNotice.
+func NewUserNotice(mssg *IRCMessage) (UserNoticeEvent, error) {
+	if mssg.Command != "USERNOTICE" {
+		return nil, fmt.Errorf("expected USERNOTICE but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	notice := UserNotice{
+		irc:         mssg,
+		Channel:     mssg.Channel(),
+		MsgID:       tags["msg-id"],
+		Login:       tags["login"],
+		DisplayName: tags.DisplayName(),
+		UserID:      tags.UserID(),
+		SystemMsg:   tags["system-msg"],
+		Text:        mssg.Param(1),
+		Params:      make(map[string]string),
+		Tags:        tags,
+	}
+	for key, value := range tags {
+		if strings.HasPrefix(key, "msg-param-") {
+			notice.Params[strings.TrimPrefix(key, "msg-param-")] = value
+		}
+	}
+
+	switch notice.MsgID {
+	case "sub", "resub":
+		return &Sub{
+			UserNotice:       notice,
+			Resub:            notice.MsgID == "resub",
+			CumulativeMonths: notice.intParam("cumulative-months"),
+			StreakMonths:     notice.intParam("streak-months"),
+			Plan:             SubPlan(notice.Params["sub-plan"]),
+			PlanName:         notice.Params["sub-plan-name"],
+		}, nil
+	case "subgift":
+		return &SubGift{
+			UserNotice:           notice,
+			Months:               notice.intParam("months"),
+			GiftMonths:           notice.intParam("gift-months"),
+			RecipientLogin:       notice.Params["recipient-user-name"],
+			RecipientDisplayName: notice.Params["recipient-display-name"],
+			RecipientID:          notice.Params["recipient-id"],
+			Plan:                 SubPlan(notice.Params["sub-plan"]),
+			PlanName:             notice.Params["sub-plan-name"],
+		}, nil
+	case "submysterygift":
+		return &MysteryGift{
+			UserNotice:  notice,
+			Count:       notice.intParam("mass-gift-count"),
+			SenderCount: notice.intParam("sender-count"),
+			Plan:        SubPlan(notice.Params["sub-plan"]),
+		}, nil
+	case "giftpaidupgrade", "anongiftpaidupgrade":
+		return &GiftPaidUpgrade{
+			UserNotice:     notice,
+			Anonymous:      notice.MsgID == "anongiftpaidupgrade",
+			SenderLogin:    notice.Params["sender-login"],
+			SenderName:     notice.Params["sender-name"],
+			PromoName:      notice.Params["promo-name"],
+			PromoGiftTotal: notice.intParam("promo-gift-total"),
+		}, nil
+	case "raid":
+		return &Raid{
+			UserNotice:        notice,
+			RaiderLogin:       notice.Params["login"],
+			RaiderDisplayName: notice.Params["displayName"],
+			ViewerCount:       notice.intParam("viewerCount"),
+		}, nil
+	case "ritual":
+		return &Ritual{
+			UserNotice: notice,
+			Name:       notice.Params["ritual-name"],
+		}, nil
+	case "announcement":
+		return &Announcement{
+			UserNotice: notice,
+			Color:      notice.Params["color"],
+		}, nil
+	case "bitsbadgetier":
+		return &BitsBadgeTier{
+			UserNotice: notice,
+			Threshold:  notice.intParam("threshold"),
+		}, nil
+	default:
+		return &notice, nil
+	}
+}
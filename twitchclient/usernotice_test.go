@@ -0,0 +1,87 @@
+package twitchclient
+
+import (
+	"testing"
+)
+
+func TestNewUserNotice_resub(t *testing.T) {
+	event := parseEvent(t, `@display-name=Ronni;login=ronni;msg-id=resub;msg-param-cumulative-months=6;msg-param-streak-months=2;msg-param-should-share-streak=1;msg-param-sub-plan=Prime;msg-param-sub-plan-name=Prime;system-msg=ronni\shas\ssubscribed\sfor\s6\smonths!;user-id=1337 :tmi.twitch.tv USERNOTICE #dallas :Great stream -- keep it up!`)
+	sub, ok := event.(*Sub)
+	if !ok {
+		t.Fatalf("Expected *Sub but got %T", event)
+	}
+	if !sub.Resub || sub.CumulativeMonths != 6 || sub.StreakMonths != 2 || sub.Plan != PlanPrime {
+		t.Errorf("Unexpected subscription: %+v", sub)
+	}
+	if sub.Channel != "dallas" || sub.Login != "ronni" || sub.DisplayName != "Ronni" || sub.UserID != "1337" {
+		t.Errorf("Unexpected user information: %+v", sub.UserNotice)
+	}
+	if sub.Text != "Great stream -- keep it up!" || sub.SystemMsg != "ronni has subscribed for 6 months!" {
+		t.Errorf("Unexpected texts: %q, %q", sub.Text, sub.SystemMsg)
+	}
+	if sub.IRC() == nil || sub.IRC().Command != "USERNOTICE" {
+		t.Errorf("Should keep parsed message")
+	}
+}
+
+func TestNewUserNotice_gifts(t *testing.T) {
+	event := parseEvent(t, `@login=tww2;msg-id=subgift;msg-param-months=1;msg-param-recipient-display-name=Mr_Woodchuck;msg-param-recipient-id=55554444;msg-param-recipient-user-name=mr_woodchuck;msg-param-sub-plan=1000 :tmi.twitch.tv USERNOTICE #forstycup`)
+	gift, ok := event.(*SubGift)
+	if !ok {
+		t.Fatalf("Expected *SubGift but got %T", event)
+	}
+	if gift.RecipientLogin != "mr_woodchuck" || gift.RecipientDisplayName != "Mr_Woodchuck" || gift.RecipientID != "55554444" || gift.Plan != PlanTier1 || gift.Months != 1 {
+		t.Errorf("Unexpected gift: %+v", gift)
+	}
+
+	event = parseEvent(t, `@login=gifter;msg-id=submysterygift;msg-param-mass-gift-count=5;msg-param-sender-count=50;msg-param-sub-plan=2000 :tmi.twitch.tv USERNOTICE #channel`)
+	mystery, ok := event.(*MysteryGift)
+	if !ok {
+		t.Fatalf("Expected *MysteryGift but got %T", event)
+	}
+	if mystery.Count != 5 || mystery.SenderCount != 50 || mystery.Plan != PlanTier2 {
+		t.Errorf("Unexpected mystery gift: %+v", mystery)
+	}
+
+	event = parseEvent(t, `@login=user;msg-id=anongiftpaidupgrade;msg-param-promo-gift-total=3;msg-param-promo-name=Subtember :tmi.twitch.tv USERNOTICE #channel`)
+	upgrade, ok := event.(*GiftPaidUpgrade)
+	if !ok {
+		t.Fatalf("Expected *GiftPaidUpgrade but got %T", event)
+	}
+	if !upgrade.Anonymous || upgrade.PromoName != "Subtember" || upgrade.PromoGiftTotal != 3 {
+		t.Errorf("Unexpected upgrade: %+v", upgrade)
+	}
+}
+
+func TestNewUserNotice_others(t *testing.T) {
+	event := parseEvent(t, `@login=raider;msg-id=raid;msg-param-displayName=Raider;msg-param-login=raider;msg-param-viewerCount=15 :tmi.twitch.tv USERNOTICE #channel`)
+	if raid, ok := event.(*Raid); !ok || raid.RaiderLogin != "raider" || raid.RaiderDisplayName != "Raider" || raid.ViewerCount != 15 {
+		t.Errorf("Unexpected raid: %#v", event)
+	}
+
+	event = parseEvent(t, `@login=new;msg-id=ritual;msg-param-ritual-name=new_chatter :tmi.twitch.tv USERNOTICE #channel :HeyGuys`)
+	if ritual, ok := event.(*Ritual); !ok || ritual.Name != "new_chatter" || ritual.Text != "HeyGuys" {
+		t.Errorf("Unexpected ritual: %#v", event)
+	}
+
+	event = parseEvent(t, `@login=mod;msg-id=announcement;msg-param-color=PURPLE :tmi.twitch.tv USERNOTICE #channel :Giveaway!`)
+	if announcement, ok := event.(*Announcement); !ok || announcement.Color != "PURPLE" || announcement.Text != "Giveaway!" {
+		t.Errorf("Unexpected announcement: %#v", event)
+	}
+
+	event = parseEvent(t, `@login=cheerer;msg-id=bitsbadgetier;msg-param-threshold=1000 :tmi.twitch.tv USERNOTICE #channel`)
+	if tier, ok := event.(*BitsBadgeTier); !ok || tier.Threshold != 1000 {
+		t.Errorf("Unexpected bits badge tier: %#v", event)
+	}
+}
+
+func TestNewUserNotice_unknown(t *testing.T) {
+	event := parseEvent(t, `@login=user;msg-id=somethingnew;msg-param-foo=bar :tmi.twitch.tv USERNOTICE #channel`)
+	notice, ok := event.(*UserNotice)
+	if !ok {
+		t.Fatalf("Expected *UserNotice but got %T", event)
+	}
+	if notice.MsgID != "somethingnew" || notice.Params["foo"] != "bar" {
+		t.Errorf("Unexpected notice: %+v", notice)
+	}
+}
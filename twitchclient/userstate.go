@@ -0,0 +1,161 @@
+package twitchclient
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// UserState contains the state of the logged in user in a channel (USERSTATE). Twitch sends it
+// after joining a channel and after every message the user sent to the channel.
+type UserState struct {
+	irc *IRCMessage
+
+	// Channel the state applies to without the leading '#'.
+	Channel string
+	// DisplayName of the user.
+	DisplayName string
+	// Color of the user in hex format. Empty if the user never set a color.
+	Color string
+	// EmoteSets the user is allowed to use in the channel.
+	EmoteSets []string
+	// Badges of the user in the channel.
+	Badges []Badge
+	// Permission level of the user in the channel.
+	Permission PermissionLevel
+	// Mod is set if the user is a moderator of the channel.
+	Mod bool
+	// VIP is set if the user is a VIP of the channel.
+	VIP bool
+	// Broadcaster is set if the user owns the channel.
+	Broadcaster bool
+	// Subscriber is set if the user is subscribed to the channel.
+	Subscriber bool
+}
+
+// IRC returns the parsed message the state was created from.
+func (state *UserState) IRC() *IRCMessage {
+	return state.irc
+}
+
+// GlobalUserState contains the state of the logged in user independent of channels (GLOBALUSERSTATE).
+// Twitch sends it after successfully logging in.
+type GlobalUserState struct {
+	irc *IRCMessage
+
+	// UserID of the user.
+	UserID string
+	// DisplayName of the user.
+	DisplayName string
+	// Color of the user in hex format. Empty if the user never set a color.
+	Color string
+	// EmoteSets the user is allowed to use in all channels.
+	EmoteSets []string
+}
+
+// IRC returns the parsed message the state was created from.
+func (state *GlobalUserState) IRC() *IRCMessage {
+	return state.irc
+}
+
+// NewUserState creates a UserState from a parsed USERSTATE.
+func NewUserState(mssg *IRCMessage) (*UserState, error) {
+	if mssg.Command != "USERSTATE" {
+		return nil, fmt.Errorf("expected USERSTATE but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	badges := tags.Badges()
+	return &UserState{
+		irc:         mssg,
+		Channel:     mssg.Channel(),
+		DisplayName: tags.DisplayName(),
+		Color:       tags.Color(),
+		EmoteSets:   splitList(tags["emote-sets"]),
+		Badges:      badges,
+		Permission:  tags.Permission(),
+		Mod:         tags.Mod(),
+		VIP:         tags.flag("vip") || HasBadge(badges, "vip"),
+		Broadcaster: HasBadge(badges, "broadcaster"),
+		Subscriber:  tags.Subscriber(),
+	}, nil
+}
+
+// NewGlobalUserState creates a GlobalUserState from a parsed GLOBALUSERSTATE.
+func NewGlobalUserState(mssg *IRCMessage) (*GlobalUserState, error) {
+	if mssg.Command != "GLOBALUSERSTATE" {
+		return nil, fmt.Errorf("expected GLOBALUSERSTATE but got %s", mssg.Command)
+	}
+	tags := mssg.Tags()
+	return &GlobalUserState{
+		irc:         mssg,
+		UserID:      tags.UserID(),
+		DisplayName: tags.DisplayName(),
+		Color:       tags.Color(),
+		EmoteSets:   splitList(tags["emote-sets"]),
+	}, nil
+}
+
+// splitList splits a comma separated tag value.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// UserState returns the state of the logged in user in a joined channel. Returns false if no
+// USERSTATE was received for the channel yet.
+func (client *Client) UserState(channel string) (UserState, bool) {
+	return client.self.get(normalizeChannel(channel))
+}
+
+// GlobalUserState returns the state of the logged in user. Returns false if no GLOBALUSERSTATE
+// was received yet, which is the case if the client wasn't connected with `WithCommands()`.
+func (client *Client) GlobalUserState() (GlobalUserState, bool) {
+	return client.self.getGlobal()
+}
+
+// selfStates stores the states of the logged in user.
+type selfStates struct {
+	lock     sync.RWMutex
+	global   *GlobalUserState
+	channels map[string]UserState
+}
+
+func newSelfStates() *selfStates {
+	return &selfStates{channels: make(map[string]UserState)}
+}
+
+func (states *selfStates) get(channel string) (UserState, bool) {
+	states.lock.RLock()
+	defer states.lock.RUnlock()
+	state, ok := states.channels[channel]
+	return state, ok
+}
+
+func (states *selfStates) getGlobal() (GlobalUserState, bool) {
+	states.lock.RLock()
+	defer states.lock.RUnlock()
+	if states.global == nil {
+		return GlobalUserState{}, false
+	}
+	return *states.global, true
+}
+
+func (states *selfStates) update(state *UserState) {
+	states.lock.Lock()
+	states.channels[state.Channel] = *state
+	states.lock.Unlock()
+}
+
+func (states *selfStates) updateGlobal(state *GlobalUserState) {
+	states.lock.Lock()
+	states.global = state
+	states.lock.Unlock()
+}
+
+func (states *selfStates) remove(channel string) {
+	states.lock.Lock()
+	delete(states.channels, channel)
+	states.lock.Unlock()
+}
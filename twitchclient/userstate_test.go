@@ -0,0 +1,47 @@
+package twitchclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClient_UserState(t *testing.T) {
+	client := New(&TwitchAuthentication{Username: "bot"})
+	if _, ok := client.UserState("channel"); ok {
+		t.Fatal("Shouldn't know the state before receiving a USERSTATE")
+	}
+	if _, ok := client.GlobalUserState(); ok {
+		t.Fatal("Shouldn't know the global state before receiving a GLOBALUSERSTATE")
+	}
+
+	trackRaw(t, client, "@badge-info=;badges=vip/1;color=#0D4200;display-name=Bot;emote-sets=0,33,50;mod=0;subscriber=0;user-type= :tmi.twitch.tv USERSTATE #channel")
+	state, ok := client.UserState("channel")
+	if !ok {
+		t.Fatal("Should know the state after receiving a USERSTATE")
+	}
+	if state.Channel != "channel" || state.DisplayName != "Bot" || state.Color != "#0D4200" {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+	if !state.VIP || state.Mod || state.Broadcaster || state.Subscriber {
+		t.Errorf("Unexpected permissions: %+v", state)
+	}
+	if !reflect.DeepEqual(state.EmoteSets, []string{"0", "33", "50"}) {
+		t.Errorf("Unexpected emote sets: %v", state.EmoteSets)
+	}
+
+	trackRaw(t, client, "@badges=broadcaster/1;mod=0 :tmi.twitch.tv USERSTATE #bot")
+	if state, _ := client.UserState("#Bot"); !state.Broadcaster {
+		t.Errorf("Should be broadcaster in own channel: %+v", state)
+	}
+
+	trackRaw(t, client, "@badge-info=;badges=;color=#0D4200;display-name=Bot;emote-sets=0,33;user-id=12345678;user-type= :tmi.twitch.tv GLOBALUSERSTATE")
+	global, ok := client.GlobalUserState()
+	if !ok || global.UserID != "12345678" || global.DisplayName != "Bot" || len(global.EmoteSets) != 2 {
+		t.Errorf("Unexpected global state: %+v", global)
+	}
+
+	trackRaw(t, client, ":bot!bot@bot.tmi.twitch.tv PART #channel")
+	if _, ok := client.UserState("channel"); ok {
+		t.Error("Should forget the state after leaving the channel")
+	}
+}